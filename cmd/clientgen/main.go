@@ -0,0 +1,76 @@
+// clientgen 根据服务输出的 /openapi.json 生成带类型的 go 客户端
+//
+//	go run ./cmd/clientgen -spec http://127.0.0.1:8080/openapi.json -pkg sdk -o ./sdk/client_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ixugo/goweb/pkg/client"
+)
+
+var (
+	spec   = flag.String("spec", "http://127.0.0.1:8080/openapi.json", "openapi document, url or file path")
+	pkg    = flag.String("pkg", "", "package name, default is the directory name of -o")
+	output = flag.String("o", "", "output file, default is stdout")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "clientgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	b, err := readSpec(*spec)
+	if err != nil {
+		return err
+	}
+
+	name := *pkg
+	if name == "" {
+		name = "client"
+		if *output != "" {
+			dir, _ := filepath.Abs(filepath.Dir(*output))
+			name = strings.ReplaceAll(filepath.Base(dir), "-", "_")
+		}
+	}
+	code, err := client.Generate(b, name)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(*output, code, 0o644)
+}
+
+func readSpec(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return os.ReadFile(s)
+	}
+	hc := http.Client{Timeout: 10 * time.Second}
+	resp, err := hc.Get(s)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", s, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
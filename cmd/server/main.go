@@ -14,6 +14,7 @@ import (
 
 	"github.com/ixugo/goweb/internal/conf"
 	"github.com/ixugo/goweb/pkg/logger"
+	"github.com/ixugo/goweb/pkg/orm"
 	"github.com/ixugo/goweb/pkg/server"
 	"github.com/ixugo/goweb/pkg/system"
 )
@@ -51,6 +52,22 @@ func main() {
 	if err := conf.SetupConfig(&bc, filePath); err != nil {
 		panic(err)
 	}
+	// 未配置 jwt 秘钥时，随机生成并写回配置，避免每次重启后全部用户登录失效
+	var changed bool
+	if bc.Server.HTTP.JwtSecret == "" && len(bc.Server.HTTP.JwtKeys) == 0 {
+		bc.Server.HTTP.JwtSecret = orm.GenerateRandomString(32)
+		changed = true
+	}
+	// 游标秘钥同样写回配置，重启后已下发的游标依然有效
+	if bc.Server.HTTP.CursorSecret == "" {
+		bc.Server.HTTP.CursorSecret = orm.GenerateRandomString(32)
+		changed = true
+	}
+	if changed {
+		if err := conf.WriteConfig(&bc, filePath); err != nil {
+			slog.Error("write config fail", "err", err)
+		}
+	}
 
 	bc.Debug = !getBuildRelease()
 	bc.BuildVersion = buildVersion
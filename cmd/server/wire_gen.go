@@ -17,18 +17,57 @@ import (
 // Injectors from wire.go:
 
 func wireApp(bc *conf.Bootstrap, log *slog.Logger) (http.Handler, func(), error) {
-	db, err := data.SetupDB(bc, log)
+	tracer, cleanup, err := data.SetupTracer(bc)
 	if err != nil {
 		return nil, nil, err
 	}
+	db, err := data.SetupDB(bc, log, tracer)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
 	core := api.NewVersion(db)
 	versionAPI := api.NewVersionAPI(core)
+	manager, cleanup2, err := api.NewJobManager(db, core)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	jobAPI := api.NewJobAPI(manager)
+	keySet, err := api.NewKeySet(bc)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	tokenManager := api.NewTokenManager(db, keySet, core)
+	v, err := api.NewRateLimitPolicies(bc)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	limiterStorer, cleanup3, err := api.NewLimiterStore(bc, db, core)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	usecase := &api.Usecase{
 		Conf:    bc,
 		DB:      db,
 		Version: versionAPI,
+		Job:     jobAPI,
+		KeySet:  keySet,
+		Tokens:  tokenManager,
+		Limits:  v,
+		Limiter: limiterStorer,
+		Tracer:  tracer,
 	}
 	handler := api.NewHTTPHandler(usecase)
 	return handler, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
 	}, nil
 }
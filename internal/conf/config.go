@@ -12,15 +12,48 @@ type Bootstrap struct {
 }
 
 type Server struct {
-	Debug bool
-	HTTP  ServerHTTP `comment:"对外提供的服务，建议由 nginx 代理"` // HTTP服务器
+	Debug          bool
+	HTTP           ServerHTTP  `comment:"对外提供的服务，建议由 nginx 代理"` // HTTP服务器
+	RateLimitStore string      `comment:"限流计数存储 memory/db，多实例部署时使用 db"`
+	RateLimits     []RateLimit `comment:"接口限流策略"`                       // 限流策略
+	Trace          ServerTrace `comment:"链路追踪，Endpoint 与 File 都为空时不启用"` // 链路追踪
+}
+
+// ServerTrace 链路追踪，导出 OTLP/JSON 格式的 span
+type ServerTrace struct {
+	ServiceName string            `comment:"服务名，为空时使用 goweb"`
+	Endpoint    string            `comment:"OTLP/HTTP 地址，如 http://127.0.0.1:4318/v1/traces"`
+	Headers     map[string]string `comment:"OTLP/HTTP 请求头"`
+	File        string            `comment:"导出到本地文件，配置 Endpoint 时忽略"`
+}
+
+// RateLimit 限流策略，按 gin 路由匹配，多个策略同时匹配时全部生效
+type RateLimit struct {
+	Method string `comment:"请求方法，为空匹配全部"`
+	Path   string `comment:"路由，如 /users/:id，以 /* 结尾时前缀匹配，* 匹配全部"`
+	Key    string `comment:"限流维度 ip/uid/route"`
+	Rate   string `comment:"速率 数量/单位，单位支持 s/min/h/day，如 5/min"`
+	Burst  int    `comment:"突发容量，为 0 时与数量一致"`
 }
 
 type ServerHTTP struct {
-	Port      int         `comment:"http 端口"`                // 服务器端口号
-	Timeout   Duration    `comment:"请求超时时间"`                 // 请求超时时间
-	JwtSecret string      `comment:"jwt 秘钥，空串时，每次启动程序将随机赋值"` // JWT密钥
-	PProf     ServerPPROF // Pprof配置
+	Port         int         `comment:"http 端口"`                               // 服务器端口号
+	Timeout      Duration    `comment:"请求超时时间"`                                // 请求超时时间
+	JwtSecret    string      `comment:"jwt 秘钥，空串且未配置 JwtKeys 时，首次启动随机生成并写回配置"` // JWT密钥
+	JwtKeys      []JwtKey    `comment:"jwt 秘钥集合，用于秘钥轮换，配置后忽略 JwtSecret"`       // JWT秘钥集合
+	CursorSecret string      `comment:"滚动翻页游标签名秘钥，空串时首次启动随机生成并写回配置，多实例部署时需相同"` // 游标秘钥
+	PProf        ServerPPROF // Pprof配置
+	ErrorFormat  string      `comment:"错误响应格式 default/problem/negotiate，negotiate 按请求头 Accept 选择 application/problem+json"` // 错误响应格式
+}
+
+// JwtKey 签名秘钥，同时只有一个秘钥用于签名，其它秘钥仅用于验签
+type JwtKey struct {
+	Kid        string `comment:"秘钥 id，写入 token 头部"`
+	Alg        string `comment:"签名算法 HS256/RS256/ES256/EdDSA"`
+	Secret     string `comment:"HS256 秘钥"`
+	PrivateKey string `comment:"私钥 PEM 文件路径，为空时仅用于验签"`
+	PublicKey  string `comment:"公钥 PEM 文件路径，为空时由私钥推导"`
+	Signing    bool   `comment:"是否用于签名"`
 }
 
 // ServerPPROF 结构体，包含 Enabled 和 AccessIps 两个字段
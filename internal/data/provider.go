@@ -1,24 +1,31 @@
 package data
 
 import (
+	"context"
 	"log/slog"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/glebarez/sqlite"
 	"github.com/google/wire"
 	"github.com/ixugo/goweb/internal/conf"
 	"github.com/ixugo/goweb/pkg/orm"
 	"github.com/ixugo/goweb/pkg/system"
+	"github.com/ixugo/goweb/pkg/web"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 // ProviderSet is data providers.
-var ProviderSet = wire.NewSet(SetupDB)
+var ProviderSet = wire.NewSet(SetupDB, SetupTracer)
 
 // SetupDB 初始化数据存储
-func SetupDB(c *conf.Bootstrap, l *slog.Logger) (*gorm.DB, error) {
+func SetupDB(c *conf.Bootstrap, l *slog.Logger, tracer *web.Tracer) (*gorm.DB, error) {
+	// 多实例部署时滚动翻页的游标通用，未配置时使用随机秘钥
+	if secret := c.Server.HTTP.CursorSecret; secret != "" {
+		orm.SetCursorSecret([]byte(secret))
+	}
 	cfg := c.Data.Database
 	dial, isSQLite := getDialector(cfg.Dsn)
 	if isSQLite {
@@ -30,8 +37,54 @@ func SetupDB(c *conf.Bootstrap, l *slog.Logger) (*gorm.DB, error) {
 		MaxOpenConns:    int(cfg.MaxOpenConns),
 		ConnMaxLifetime: cfg.ConnMaxLifetime.Duration(),
 		SlowThreshold:   cfg.SlowThreshold.Duration(),
-	}, orm.NewLogger(l, c.Debug, cfg.SlowThreshold.Duration()))
-	return db, err
+	}, orm.NewLogger(l, c.Debug, cfg.SlowThreshold.Duration()).WithContext(web.LoggerFromContext))
+	if err != nil {
+		return nil, err
+	}
+	if tracer != nil {
+		if err := db.Use(web.NewDBTracePlugin(tracer)); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// SetupTracer 初始化链路追踪，未配置时返回 nil
+func SetupTracer(c *conf.Bootstrap) (*web.Tracer, func(), error) {
+	cfg := c.Server.Trace
+	name := cfg.ServiceName
+	if name == "" {
+		name = "goweb"
+	}
+
+	var exporter web.SpanExporter
+	closeFn := func() error { return nil }
+	switch {
+	case cfg.Endpoint != "":
+		exporter = web.NewOTLPExporter(cfg.Endpoint, cfg.Headers)
+	case cfg.File != "":
+		path := cfg.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(system.Getwd(), path)
+		}
+		exp, fn, err := web.NewFileExporter(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, closeFn = exp, fn
+	default:
+		return nil, func() {}, nil
+	}
+
+	tracer := web.NewTracer(name, exporter)
+	return tracer, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracer.Shutdown(ctx); err != nil {
+			slog.Error("tracer shutdown", "err", err)
+		}
+		_ = closeFn()
+	}, nil
 }
 
 // getDialector 返回 dial 和 是否 sqlite
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"database/sql"
 	"expvar"
 	"log/slog"
 	"net/http"
@@ -21,22 +22,50 @@ func setupRouter(r *gin.Engine, uc *Usecase) {
 		// 格式化输出到控制台，然后记录到日志
 		// 此处不做 recover，底层 http.server 也会 recover，但不会输出方便查看的格式
 		gin.CustomRecovery(func(c *gin.Context, err any) {
+			// 由 http.Server 中断连接，例如导出中途失败
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
 			slog.Error("panic", "err", err, "stack", string(debug.Stack()))
 			c.AbortWithStatus(http.StatusInternalServerError)
 		}),
 		web.Metrics(),
+		web.Tracing(uc.Tracer),
+		web.Locale(),
 		web.Logger(slog.Default(), func(_ *gin.Context) bool {
 			// true:记录请求响应报文
 			return uc.Conf.Server.Debug
 		}),
 	)
 	go web.CountGoroutines(10*time.Minute, 20)
+	go web.CountLatency(time.Minute, 60)
 
-	auth := web.AuthMiddleware(uc.Conf.Server.HTTP.JwtSecret)
-	r.GET("/health", web.WarpH(uc.getHealth))
-	r.GET("/app/metrics/api", web.WarpH(uc.getMetricsAPI))
+	// 按 uid 限流的策略需要身份，鉴权中间件之后再挂载一次，已执行的策略不会重复计数
+	limiter := web.RateLimitByPolicy(uc.Limiter, uc.Limits...)
+	r.Use(limiter)
 
-	registerVersion(r, uc.Version, auth)
+	if uc.Conf.BuildVersion != "" {
+		web.DefaultOpenAPI.Version = uc.Conf.BuildVersion
+	}
+	auth := uc.Tokens.AuthMiddleware()
+	web.Handle(r, http.MethodGet, "/health", uc.getHealth, web.WithTags("system"), web.WithSummary("健康检查"))
+	web.Handle(r, http.MethodGet, "/app/metrics/api", uc.getMetricsAPI, web.WithTags("system"), web.WithSummary("接口统计"))
+	r.GET("/metrics", web.PrometheusHandler(dbStatsCollector(uc)...))
+	r.GET("/app/errors", web.ErrorCatalogHandler())
+	r.GET("/openapi.json", web.OpenAPIHandler())
+	r.GET("/openapi", web.OpenAPIViewer("/openapi.json"))
+
+	registerVersion(r, uc.Version, auth, limiter)
+	registerJob(r, uc.Job, auth, limiter)
+}
+
+func dbStatsCollector(uc *Usecase) []web.PromCollector {
+	sqlDB, err := uc.DB.DB()
+	if err != nil {
+		slog.Error("db stats", "err", err)
+		return nil
+	}
+	return []web.PromCollector{web.DBStatsCollector(map[string]*sql.DB{"default": sqlDB})}
 }
 
 type getHealthOutput struct {
@@ -65,6 +94,10 @@ type getMetricsAPIOutput struct {
 	NumGC            uint32 `json:"num_gc"`             // gc 次数
 	SysAlloc         uint64 `json:"sys_alloc"`          // 内存占用
 	StartAt          string `json:"start_at"`           // 运行时间
+
+	Latency        map[string]web.WindowStats `json:"latency"`         // 合计的耗时分位数、错误率与吞吐量，key 为 1m/5m/1h
+	Routes         []web.RouteStats           `json:"routes"`          // 各路由的统计
+	LatencyHistory any                        `json:"latency_history"` // 每分钟的合计统计
 }
 
 func (uc *Usecase) getMetricsAPI(_ *gin.Context, _ *struct{}) (*getMetricsAPIOutput, error) {
@@ -76,6 +109,10 @@ func (uc *Usecase) getMetricsAPI(_ *gin.Context, _ *struct{}) (*getMetricsAPIOut
 	u := sortExpvarMap(urls, 10)
 	s := sortExpvarMap(status, 10)
 	g := expvar.Get("goroutine_num").(expvar.Func)
+	var history any
+	if fn, ok := expvar.Get("latency_history").(expvar.Func); ok {
+		history = fn()
+	}
 
 	var stats runtime.MemStats
 	runtime.ReadMemStats(&stats)
@@ -90,6 +127,9 @@ func (uc *Usecase) getMetricsAPI(_ *gin.Context, _ *struct{}) (*getMetricsAPIOut
 		NumGC:            stats.NumGC,
 		SysAlloc:         stats.Sys,
 		StartAt:          startRuntime.Format(time.DateTime),
+		Latency:          web.LatencyTotal(),
+		Routes:           web.LatencyRoutes(),
+		LatencyHistory:   history,
 	}, nil
 }
 
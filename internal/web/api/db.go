@@ -2,6 +2,6 @@ package api
 
 // 如果需要执行表迁移，递增此版本号和表更新说明
 var (
-	dbVersion = "0.0.1"
-	dbRemark  = "debug"
+	dbVersion = "0.0.4"
+	dbRemark  = "add rate limit buckets"
 )
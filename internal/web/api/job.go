@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ixugo/goweb/pkg/job"
+	"github.com/ixugo/goweb/pkg/web"
+)
+
+type JobAPI struct {
+	m *job.Manager
+}
+
+func NewJobAPI(m *job.Manager) JobAPI {
+	return JobAPI{m: m}
+}
+
+func registerJob(r gin.IRouter, api JobAPI, handler ...gin.HandlerFunc) {
+	{
+		group := r.Group("/jobs", handler...)
+		web.Handle(group, http.MethodGet, "", api.findJob, web.WithTags("job"), web.WithSummary("任务列表"))
+		web.Handle(group, http.MethodGet, "/:id", api.getJob, web.WithTags("job"), web.WithSummary("任务详情"), web.WithErrors(web.ErrNotFound))
+		web.Handle(group, http.MethodPost, "/:id/cancel", api.cancelJob, web.WithTags("job"), web.WithSummary("取消任务"), web.WithErrors(web.ErrNotFound))
+		// 断线后按任务 ID 重新请求即可继续获取进度
+		group.GET("/:id/progress", api.progress)
+		group.GET("/:id/events", api.events)
+	}
+}
+
+type jobIDInput struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+type findJobOutput struct {
+	Items []*job.Job `json:"items"`
+	Total int64      `json:"total"`
+}
+
+func (a JobAPI) findJob(c *gin.Context, in *job.FindJobInput) (findJobOutput, error) {
+	in.Owner = web.GetUID(c)
+	items, total, err := a.m.Find(c.Request.Context(), *in)
+	if err != nil {
+		return findJobOutput{}, web.ErrDB.Withf("job find err[%s]", err)
+	}
+	return findJobOutput{Items: items, Total: total}, nil
+}
+
+func (a JobAPI) getJob(c *gin.Context, in *jobIDInput) (*job.Job, error) {
+	return a.ownJob(c, in.ID)
+}
+
+func (a JobAPI) cancelJob(c *gin.Context, in *jobIDInput) (*job.Job, error) {
+	if _, err := a.ownJob(c, in.ID); err != nil {
+		return nil, err
+	}
+	if err := a.m.Cancel(c.Request.Context(), in.ID); err != nil {
+		return nil, err
+	}
+	return a.m.Get(c.Request.Context(), in.ID)
+}
+
+func (a JobAPI) progress(c *gin.Context) {
+	if _, err := a.ownJob(c, c.Param("id")); err != nil {
+		web.Fail(c, err)
+		return
+	}
+	a.m.SendChunk(c, c.Param("id"))
+}
+
+func (a JobAPI) events(c *gin.Context) {
+	if _, err := a.ownJob(c, c.Param("id")); err != nil {
+		web.Fail(c, err)
+		return
+	}
+	a.m.ServeSSE(c, c.Param("id"))
+}
+
+// ownJob 只能访问自己提交的任务
+func (a JobAPI) ownJob(c *gin.Context, id string) (*job.Job, error) {
+	j, err := a.m.Get(c.Request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Owner != web.GetUID(c) {
+		return nil, web.ErrNotFound
+	}
+	return j, nil
+}
@@ -1,15 +1,21 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"path/filepath"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 	"github.com/ixugo/goweb/internal/conf"
 	"github.com/ixugo/goweb/internal/core/version"
 	"github.com/ixugo/goweb/internal/core/version/store/versiondb"
+	"github.com/ixugo/goweb/pkg/job"
 	"github.com/ixugo/goweb/pkg/orm"
+	"github.com/ixugo/goweb/pkg/system"
 	"github.com/ixugo/goweb/pkg/web"
 	"gorm.io/gorm"
 )
@@ -20,6 +26,12 @@ var (
 		wire.Struct(new(Usecase), "*"),
 		NewHTTPHandler,
 		NewVersionAPI,
+		NewKeySet,
+		NewTokenManager,
+		NewRateLimitPolicies,
+		NewLimiterStore,
+		NewJobManager,
+		NewJobAPI,
 	)
 )
 
@@ -27,19 +39,27 @@ type Usecase struct {
 	Conf    *conf.Bootstrap
 	DB      *gorm.DB
 	Version VersionAPI
+	Job     JobAPI
+	KeySet  *web.KeySet
+	Tokens  *web.TokenManager
+	Limits  []web.RateLimitPolicy
+	Limiter web.LimiterStorer
+	Tracer  *web.Tracer
 }
 
 // NewHTTPHandler 生成Gin框架路由内容
 func NewHTTPHandler(uc *Usecase) http.Handler {
 	cfg := uc.Conf.Server
-	// 检查是否设置了 JWT 密钥，如果未设置，则生成一个长度为 32 的随机字符串作为密钥
-	if cfg.HTTP.JwtSecret == "" {
-		cfg.HTTP.JwtSecret = orm.GenerateRandomString(32) // 生成一个长度为 32 的随机字符串作为密钥
-	}
 	// 如果不处于调试模式，将 Gin 设置为发布模式
 	if !cfg.Debug {
 		gin.SetMode(gin.ReleaseMode) // 将 Gin 设置为发布模式
 	}
+	switch cfg.HTTP.ErrorFormat {
+	case "problem":
+		web.SetErrorFormat(web.ErrorFormatProblem)
+	case "negotiate":
+		web.SetErrorFormat(web.ErrorFormatNegotiate)
+	}
 	g := gin.New() // 创建一个新的 Gin 实例
 	// 处理未找到路由的情况，返回 JSON 格式的 404 错误信息
 	g.NoRoute(func(c *gin.Context) {
@@ -70,3 +90,92 @@ func NewVersion(db *gorm.DB) version.Core {
 	orm.EnabledAutoMigrate = isOK
 	return core
 }
+
+// NewJobManager 启动后台任务，version.Core 用于确定是否执行表迁移
+func NewJobManager(db *gorm.DB, _ version.Core) (*job.Manager, func(), error) {
+	m := job.NewManager(job.NewDB(db).AutoMigrate(orm.EnabledAutoMigrate), job.Config{})
+	if err := m.Start(context.Background()); err != nil {
+		return nil, nil, err
+	}
+	return m, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := m.Stop(ctx); err != nil {
+			slog.Error("job stop", "err", err)
+		}
+	}, nil
+}
+
+// NewTokenManager 令牌签发与吊销，吊销列表存储于数据库，多实例间共享
+func NewTokenManager(db *gorm.DB, ks *web.KeySet, _ version.Core) *web.TokenManager {
+	return web.NewTokenManager(ks, web.NewDBRevokeStore(db).AutoMigrate(orm.EnabledAutoMigrate))
+}
+
+// NewKeySet 根据配置加载 jwt 秘钥集合
+// 未配置 JwtKeys 时，使用 JwtSecret 作为 HS256 秘钥
+func NewKeySet(bc *conf.Bootstrap) (*web.KeySet, error) {
+	cfg := bc.Server.HTTP
+	if len(cfg.JwtKeys) == 0 {
+		if cfg.JwtSecret == "" {
+			return nil, fmt.Errorf("未配置 jwt 秘钥")
+		}
+		return web.NewKeySetFromSecret(cfg.JwtSecret), nil
+	}
+
+	ks, _ := web.NewKeySet()
+	for _, v := range cfg.JwtKeys {
+		var key *web.JWTKey
+		var err error
+		if v.Alg == web.AlgHS256 {
+			key, err = web.NewJWTKey(v.Kid, v.Alg, v.Secret, nil)
+		} else {
+			key, err = web.LoadPEMKey(v.Kid, v.Alg, absPath(v.PrivateKey), absPath(v.PublicKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwt 秘钥 %s: %w", v.Kid, err)
+		}
+		if err := ks.Add(key); err != nil {
+			return nil, err
+		}
+		if v.Signing {
+			if err := ks.SetSigner(v.Kid); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := ks.Signer(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// NewRateLimitPolicies 根据配置加载限流策略
+func NewRateLimitPolicies(bc *conf.Bootstrap) ([]web.RateLimitPolicy, error) {
+	out := make([]web.RateLimitPolicy, 0, len(bc.Server.RateLimits))
+	for _, v := range bc.Server.RateLimits {
+		p, err := web.NewRateLimitPolicy(v.Method, v.Path, v.Key, v.Rate, v.Burst)
+		if err != nil {
+			return nil, fmt.Errorf("限流策略 %s %s: %w", v.Method, v.Path, err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// NewLimiterStore 限流计数存储，version.Core 用于确定是否执行表迁移
+func NewLimiterStore(bc *conf.Bootstrap, db *gorm.DB, _ version.Core) (web.LimiterStorer, func(), error) {
+	if bc.Server.RateLimitStore == "db" {
+		s := web.NewDBLimiterStore(db, 5*time.Minute, 30*time.Minute).AutoMigrate(orm.EnabledAutoMigrate)
+		return s, s.Stop, nil
+	}
+	s := web.NewMemoryLimiterStore(time.Minute, 10*time.Minute)
+	return s, s.Stop, nil
+}
+
+// absPath 相对路径以工作目录为准
+func absPath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(system.Getwd(), path)
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/ixugo/goweb/internal/core/version"
 	"github.com/ixugo/goweb/pkg/web"
@@ -17,7 +19,7 @@ func NewVersionAPI(ver version.Core) VersionAPI {
 func registerVersion(r gin.IRouter, verAPI VersionAPI, handler ...gin.HandlerFunc) {
 	{
 		group := r.Group("/version", handler...)
-		group.GET("", web.WarpH(verAPI.getVersion))
+		web.Handle(group, http.MethodGet, "", verAPI.getVersion, web.WithTags("version"), web.WithSummary("数据库版本"))
 	}
 }
 
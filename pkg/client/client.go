@@ -0,0 +1,357 @@
+// Package client 调用基于 goweb 的服务
+// 入参使用与 web.Bind 相同的标签，uri 替换路径参数，form 写入查询参数，header 写入请求头
+// 有请求体的方法将入参编码为 json，非请求体字段需使用 json:"-"，含有 *File 字段时使用 multipart 表单
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ixugo/goweb/pkg/web"
+)
+
+// Error 服务端返回的错误，可通过 errors.Is(err, web.ErrNotFound) 判断
+type Error struct {
+	Status  int    // http status code
+	TraceID string // 服务端的 trace id，用于排查日志
+	Err     *web.Error
+}
+
+func (e *Error) Error() string {
+	if e.TraceID == "" {
+		return fmt.Sprintf("%d %s: %s", e.Status, e.Err.Reason(), e.Err.Error())
+	}
+	return fmt.Sprintf("%d %s: %s trace_id=%s", e.Status, e.Err.Reason(), e.Err.Error(), e.TraceID)
+}
+
+// Unwrap 返回 *web.Error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// File 上传的文件
+type File struct {
+	Name   string
+	Reader io.Reader
+}
+
+// Client goweb 服务的 http 客户端
+type Client struct {
+	baseURL string
+	hc      *http.Client
+	header  http.Header
+	retry   int
+	backoff time.Duration
+}
+
+// Option ...
+type Option func(*Client)
+
+// WithHTTPClient 默认超时 30 秒
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.hc = hc }
+}
+
+// WithHeader 每个请求携带的请求头，如 Authorization
+func WithHeader(key, value string) Option {
+	return func(c *Client) { c.header.Set(key, value) }
+}
+
+// WithRetry 幂等请求的重试次数与首次重试的间隔，间隔按指数增长，默认重试 2 次，间隔 200ms
+func WithRetry(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retry = n
+		c.backoff = backoff
+	}
+}
+
+// New baseURL 如 http://127.0.0.1:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		hc:      &http.Client{Timeout: 30 * time.Second},
+		header:  make(http.Header),
+		retry:   2,
+		backoff: 200 * time.Millisecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	return &c
+}
+
+// Do 发起请求，path 支持 /users/:id 与 /users/{id}
+// in 可为 nil，out 为 nil 时忽略响应体
+// 非 2xx 响应返回 *Error，ctx 中的 trace id 与 traceparent 会传递给服务端
+func (c *Client) Do(ctx context.Context, method, path string, in, out any) error {
+	req, err := c.encode(method, path, in)
+	if err != nil {
+		return err
+	}
+
+	var resp *http.Response
+	for i := 0; ; i++ {
+		r, err := req.build(ctx)
+		if err != nil {
+			return err
+		}
+		resp, err = c.hc.Do(r)
+		if i >= c.retry || !isIdempotent(method) || !shouldRetry(ctx, resp, err) {
+			if err != nil {
+				return err
+			}
+			break
+		}
+		wait := c.backoff << i
+		wait += rand.N(wait/4 + 1)
+		if resp != nil {
+			if v, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && v > 0 {
+				wait = max(wait, time.Duration(v)*time.Second)
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	defer resp.Body.Close()
+
+	if err := web.DecodeError(resp); err != nil {
+		var e *web.Error
+		if !errors.As(err, &e) {
+			return err
+		}
+		return &Error{Status: resp.StatusCode, TraceID: resp.Header.Get(web.HeaderRequestID), Err: e}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// request 编码后的请求，请求体缓存在内存中以便重试
+type request struct {
+	method      string
+	url         string
+	header      http.Header
+	body        []byte
+	contentType string
+}
+
+func (r *request) build(ctx context.Context) (*http.Request, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.header.Clone()
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if id, ok := web.TraceID(ctx); ok && id != "" {
+		req.Header.Set(web.HeaderRequestID, id)
+	}
+	web.InjectTraceParent(ctx, req.Header)
+	return req, nil
+}
+
+func (c *Client) encode(method, path string, in any) (*request, error) {
+	r := request{method: method, header: c.header.Clone()}
+	r.header.Set("Accept", "application/json")
+	query := make(url.Values)
+	form := make(url.Values)
+	// 没有 json 标签的 form 字段，无文件时写入查询参数，有文件时仅写入 multipart 表单
+	formOnly := make(url.Values)
+	var files map[string]*File
+	hasBody := method != http.MethodGet && method != http.MethodHead && method != http.MethodDelete && method != http.MethodOptions
+
+	v := reflect.ValueOf(in)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		walkFields(v, func(f reflect.StructField, fv reflect.Value) {
+			if name := tagName(f, "uri"); name != "" {
+				s := url.PathEscape(strings.Join(formatValue(fv), ","))
+				path = strings.ReplaceAll(path, ":"+name, s)
+				path = strings.ReplaceAll(path, "{"+name+"}", s)
+				return
+			}
+			if file, ok := fv.Interface().(*File); ok {
+				// 服务端仅绑定声明了 form 标签的文件字段
+				if name := tagName(f, "form"); name != "" && file != nil {
+					if files == nil {
+						files = make(map[string]*File)
+					}
+					files[name] = file
+				}
+				return
+			}
+			if fv.IsZero() {
+				return
+			}
+			if name := tagName(f, "header"); name != "" {
+				for _, s := range formatValue(fv) {
+					r.header.Add(name, s)
+				}
+			}
+			if name := tagName(f, "form"); name != "" {
+				// map 编码为 name[key]=value
+				if fv.Kind() == reflect.Map {
+					iter := fv.MapRange()
+					for iter.Next() {
+						k := name + "[" + fmt.Sprint(iter.Key().Interface()) + "]"
+						query[k] = append(query[k], formatValue(iter.Value())...)
+					}
+					return
+				}
+				// 有请求体时 json 字段写入请求体，其余写入查询参数
+				switch {
+				case !hasBody:
+					query[name] = append(query[name], formatValue(fv)...)
+				case tagName(f, "json") == "":
+					formOnly[name] = append(formOnly[name], formatValue(fv)...)
+				}
+				form[name] = append(form[name], formatValue(fv)...)
+			}
+		})
+	}
+
+	if files == nil {
+		for k, vs := range formOnly {
+			query[k] = append(query[k], vs...)
+		}
+	}
+	r.url = c.baseURL + path
+	if len(query) > 0 {
+		r.url += "?" + query.Encode()
+	}
+	if !hasBody || in == nil {
+		return &r, nil
+	}
+
+	if files != nil {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for k, vs := range form {
+			for _, s := range vs {
+				if err := w.WriteField(k, s); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for k, f := range files {
+			fw, err := w.CreateFormFile(k, f.Name)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(fw, f.Reader); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		r.body = buf.Bytes()
+		r.contentType = w.FormDataContentType()
+		return &r, nil
+	}
+
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	r.body = b
+	r.contentType = "application/json"
+	return &r, nil
+}
+
+// walkFields 遍历导出字段，展开嵌入的结构体
+func walkFields(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			walkFields(fv, fn)
+			continue
+		}
+		if f.IsExported() {
+			fn(f, fv)
+		}
+	}
+}
+
+func tagName(f reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func formatValue(v reflect.Value) []string {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return []string{""}
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return []string{x.Format(time.RFC3339Nano)}
+	case fmt.Stringer:
+		return []string{x.String()}
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		out := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, formatValue(v.Index(i))...)
+		}
+		return out
+	}
+	return []string{fmt.Sprint(v.Interface())}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ixugo/goweb/pkg/web"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Page  int    `json:"page"`
+}
+
+type getUserInput struct {
+	ID    int    `uri:"id" json:"-"`
+	Token string `header:"X-Token" json:"-"`
+	Page  int    `form:"page,default=1" json:"-"`
+}
+
+type createUserInput struct {
+	Name  string `json:"name" binding:"required"`
+	Trace string `form:"trace" json:"-"`
+}
+
+type uploadInput struct {
+	Remark string                `form:"remark"`
+	File   *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	old := web.DefaultOpenAPI
+	web.DefaultOpenAPI = web.NewOpenAPI("test", "0.1.0")
+	t.Cleanup(func() { web.DefaultOpenAPI = old })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(web.Logger(slog.Default(), func(*gin.Context) bool { return false }))
+	g := r.Group("/users")
+	web.Handle(g, http.MethodGet, "/:id", func(_ *gin.Context, in *getUserInput) (*user, error) {
+		if in.ID == 404 {
+			return nil, web.ErrNotFound.With("user 404")
+		}
+		return &user{ID: in.ID, Token: in.Token, Page: in.Page}, nil
+	}, web.WithSummary("用户详情"), web.WithErrors(web.ErrNotFound))
+	web.Handle(g, http.MethodPost, "", func(_ *gin.Context, in *createUserInput) (user, error) {
+		return user{Name: in.Name + in.Trace}, nil
+	})
+	web.Handle(r, http.MethodPut, "/files", func(_ *gin.Context, in *uploadInput) (string, error) {
+		f, err := in.File.Open()
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		b, _ := io.ReadAll(f)
+		return in.Remark + ":" + in.File.Filename + ":" + string(b), nil
+	})
+	r.GET("/openapi.json", web.OpenAPIHandler())
+	s := httptest.NewServer(r)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestClient(t *testing.T) {
+	s := newServer(t)
+	c := New(s.URL, WithRetry(0, 0))
+	ctx := context.Background()
+
+	var u user
+	require.NoError(t, c.Do(ctx, http.MethodGet, "/users/{id}", &getUserInput{ID: 7, Token: "t"}, &u))
+	require.Equal(t, user{ID: 7, Token: "t", Page: 1}, u)
+
+	require.NoError(t, c.Do(ctx, http.MethodGet, "/users/:id", &getUserInput{ID: 7, Page: 3}, &u))
+	require.Equal(t, 3, u.Page)
+
+	require.NoError(t, c.Do(ctx, http.MethodPost, "/users", &createUserInput{Name: "a", Trace: "b"}, &u))
+	require.Equal(t, "ab", u.Name)
+
+	// 错误转换为 *web.Error，并携带 trace id
+	err := c.Do(ctx, http.MethodGet, "/users/404", nil, &u)
+	require.ErrorIs(t, err, web.ErrNotFound)
+	var e *Error
+	require.True(t, errors.As(err, &e))
+	require.Equal(t, http.StatusNotFound, e.Status)
+	require.NotEmpty(t, e.TraceID)
+	require.Equal(t, "ErrNotFound", e.Err.Reason())
+
+	err = c.Do(ctx, http.MethodPost, "/users", &createUserInput{}, &u)
+	require.ErrorIs(t, err, web.ErrBadRequest)
+	require.True(t, errors.As(err, &e))
+	require.Len(t, e.Err.Fields(), 1)
+	require.Equal(t, "name", e.Err.Fields()[0].Field)
+
+	// 传递 trace id
+	ctx = web.WithTraceID(ctx, "0123456789abcdef0123456789abcdef")
+	err = c.Do(ctx, http.MethodGet, "/users/404", nil, nil)
+	require.True(t, errors.As(err, &e))
+	require.Equal(t, "0123456789abcdef0123456789abcdef", e.TraceID)
+
+	var out string
+	require.NoError(t, c.Do(ctx, http.MethodPut, "/files", &struct {
+		Remark string `form:"remark"`
+		File   *File  `form:"file"`
+	}{Remark: "r", File: &File{Name: "a.txt", Reader: strings.NewReader("hello")}}, &out))
+	require.Equal(t, "r:a.txt:hello", out)
+
+	// 有文件时 form 字段仅写入表单
+	req, err := c.encode(http.MethodPut, "/files", &struct {
+		Remark string `form:"remark"`
+		File   *File  `form:"file"`
+	}{Remark: "r", File: &File{Name: "a.txt", Reader: strings.NewReader("hello")}})
+	require.NoError(t, err)
+	require.NotContains(t, req.url, "remark")
+	require.Contains(t, string(req.body), "remark")
+	req, err = c.encode(http.MethodPut, "/files", &struct {
+		Remark string `form:"remark"`
+	}{Remark: "r"})
+	require.NoError(t, err)
+	require.Contains(t, req.url, "remark=r")
+}
+
+func TestClientRetry(t *testing.T) {
+	var count atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer s.Close()
+
+	c := New(s.URL, WithRetry(2, time.Millisecond))
+	var out map[string]bool
+	require.NoError(t, c.Do(context.Background(), http.MethodGet, "/", nil, &out))
+	require.True(t, out["ok"])
+	require.EqualValues(t, 3, count.Load())
+
+	// 非幂等请求不重试，非 goweb 格式的错误按 status code 转换
+	count.Store(0)
+	err := c.Do(context.Background(), http.MethodPost, "/", nil, &out)
+	require.ErrorIs(t, err, web.ErrServer)
+	require.EqualValues(t, 1, count.Load())
+}
+
+func TestGenerate(t *testing.T) {
+	s := newServer(t)
+	resp, err := http.Get(s.URL + "/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	spec, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	code, err := Generate(spec, "sdk")
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "client_gen.go", code, 0)
+	require.NoError(t, err, string(code))
+
+	// 忽略 gofmt 的对齐
+	src := strings.Join(strings.Fields(string(code)), " ")
+	require.Contains(t, src, "package sdk")
+	require.Contains(t, src, "type User struct")
+	require.Contains(t, src, "// GetUsersByID 用户详情")
+	require.Contains(t, src, "func (c *Client) GetUsersByID(ctx context.Context, in *GetUsersByIDInput) (*User, error)")
+	require.Contains(t, src, "ID int64 `uri:\"id\" json:\"-\"`")
+	require.Contains(t, src, "XToken string `header:\"X-Token\" json:\"-\"`")
+	require.Contains(t, src, "func (c *Client) PostUsers(ctx context.Context, in *PostUsersInput) (*User, error)")
+	require.Contains(t, src, "Name string `json:\"name\"`")
+	require.Contains(t, src, "File *client.File `form:\"file\" json:\"-\"`")
+	require.Contains(t, src, "func (c *Client) PutFiles(ctx context.Context, in *PutFilesInput) (string, error)")
+}
+
+func TestExportName(t *testing.T) {
+	for in, want := range map[string]string{
+		"get_users_by_id": "GetUsersByID",
+		"X-Token":         "XToken",
+		"openAPIUser":     "OpenAPIUser",
+		"trace_id":        "TraceID",
+		"1st":             "N1st",
+	} {
+		require.Equal(t, want, exportName(in))
+	}
+}
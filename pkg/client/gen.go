@@ -0,0 +1,339 @@
+package client
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// 生成代码使用的 OpenAPI 文档结构，仅包含 web.OpenAPI 输出的部分
+type specDoc struct {
+	Paths      map[string]map[string]*specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*specSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	OperationID string `json:"operationId"`
+	Summary     string `json:"summary"`
+	Parameters  []struct {
+		Name     string      `json:"name"`
+		In       string      `json:"in"`
+		Required bool        `json:"required"`
+		Schema   *specSchema `json:"schema"`
+	} `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type specSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 any                    `json:"type"` // 3.1 中可能为数组，如 ["string","null"]
+	Format               string                 `json:"format"`
+	Description          string                 `json:"description"`
+	Items                *specSchema            `json:"items"`
+	Properties           map[string]*specSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+}
+
+func (s *specSchema) typ() string {
+	switch v := s.Type.(type) {
+	case string:
+		return v
+	case []any:
+		for _, t := range v {
+			if t, ok := t.(string); ok && t != "null" {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+// Generate 根据 OpenAPI 文档生成带类型的客户端代码，文档通常来自 web.OpenAPIHandler
+// 每个接口生成一个方法，入参结构体使用与 web.Bind 相同的标签
+func Generate(spec []byte, pkg string) ([]byte, error) {
+	var doc specDoc
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse openapi: %w", err)
+	}
+	g := generator{doc: &doc, names: make(map[string]string)}
+	return g.run(pkg)
+}
+
+type generator struct {
+	doc     *specDoc
+	names   map[string]string // component 名称对应的 go 类型名
+	useTime bool
+	ops     int
+	body    bytes.Buffer
+}
+
+func (g *generator) run(pkg string) ([]byte, error) {
+	components := sortedKeys(g.doc.Components.Schemas)
+	for _, name := range components {
+		goName := exportName(name)
+		if goName == "Client" {
+			goName = "ClientSchema"
+		}
+		g.names[name] = goName
+	}
+
+	for _, name := range components {
+		s := g.doc.Components.Schemas[name]
+		g.comment(g.names[name], s.Description)
+		fmt.Fprintf(&g.body, "type %s %s\n\n", g.names[name], g.goType(s, true))
+	}
+
+	fmt.Fprintf(&g.body, "// Client ...\ntype Client struct {\n*client.Client\n}\n\n")
+	fmt.Fprintf(&g.body, "// NewClient baseURL 如 http://127.0.0.1:8080\nfunc NewClient(baseURL string, opts ...client.Option) *Client {\nreturn &Client{Client: client.New(baseURL, opts...)}\n}\n\n")
+
+	for _, path := range sortedKeys(g.doc.Paths) {
+		ops := g.doc.Paths[path]
+		for _, method := range sortedKeys(ops) {
+			if err := g.operation(method, path, ops[method]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by clientgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	if g.ops > 0 {
+		out.WriteString("\"context\"\n\"net/http\"\n")
+	}
+	if g.useTime {
+		out.WriteString("\"time\"\n")
+	}
+	out.WriteString("\n\"github.com/ixugo/goweb/pkg/client\"\n)\n\n")
+	out.Write(g.body.Bytes())
+	b, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format: %w", err)
+	}
+	return b, nil
+}
+
+func (g *generator) comment(name, desc string) {
+	if desc == "" {
+		desc = "..."
+	}
+	fmt.Fprintf(&g.body, "// %s %s\n", name, strings.ReplaceAll(desc, "\n", " "))
+}
+
+func (g *generator) operation(method, path string, op *specOperation) error {
+	name := exportName(op.OperationID)
+	if name == "" {
+		name = exportName(strings.ToLower(method) + "_" + path)
+	}
+	httpMethod, ok := httpMethods[strings.ToUpper(method)]
+	if !ok {
+		return fmt.Errorf("unsupported method %s %s", method, path)
+	}
+
+	g.ops++
+
+	// 入参
+	var fields []string
+	for _, p := range op.Parameters {
+		tag := map[string]string{"path": "uri", "query": "form", "header": "header"}[p.In]
+		if tag == "" {
+			continue
+		}
+		typ := "string"
+		if p.Schema != nil {
+			typ = g.goType(p.Schema, false)
+		}
+		fields = append(fields, fmt.Sprintf("%s %s `%s:\"%s\" json:\"-\"`", exportName(p.Name), typ, tag, p.Name))
+	}
+	if op.RequestBody != nil {
+		for _, contentType := range sortedKeys(op.RequestBody.Content) {
+			schema := g.resolve(op.RequestBody.Content[contentType].Schema)
+			if schema == nil {
+				continue
+			}
+			multipart := contentType == "multipart/form-data"
+			for _, prop := range sortedKeys(schema.Properties) {
+				s := schema.Properties[prop]
+				typ := g.goType(s, false)
+				tag := fmt.Sprintf(`json:"%s%s"`, prop, omitempty(schema.Required, prop))
+				if multipart {
+					tag = fmt.Sprintf(`form:"%s" json:"-"`, prop)
+					if s.Format == "binary" {
+						typ = "*client.File"
+					}
+				}
+				fields = append(fields, fmt.Sprintf("%s %s `%s`", exportName(prop), typ, tag))
+			}
+			break
+		}
+	}
+
+	// 出参
+	output := ""
+	for _, code := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		if v, ok := op.Responses[code].Content["application/json"]; ok && v.Schema != nil {
+			output = g.goType(v.Schema, false)
+		}
+		break
+	}
+
+	inputName := name + "Input"
+	if len(fields) > 0 {
+		fmt.Fprintf(&g.body, "// %s %s 的入参\ntype %s struct {\n%s\n}\n\n", inputName, name, inputName, strings.Join(fields, "\n"))
+	}
+
+	g.comment(name, cmp.Or(op.Summary, strings.ToUpper(method)+" "+path))
+	params := "ctx context.Context"
+	in := "nil"
+	if len(fields) > 0 {
+		params += ", in *" + inputName
+		in = "in"
+	}
+	switch {
+	case output == "":
+		fmt.Fprintf(&g.body, "func (c *Client) %s(%s) error {\nreturn c.Do(ctx, %s, %q, %s, nil)\n}\n\n", name, params, httpMethod, path, in)
+	case !strings.HasPrefix(output, "struct") && !slices.Contains(slices.Collect(maps.Values(g.names)), output):
+		fmt.Fprintf(&g.body, "func (c *Client) %s(%s) (%s, error) {\nvar out %s\nerr := c.Do(ctx, %s, %q, %s, &out)\nreturn out, err\n}\n\n", name, params, output, output, httpMethod, path, in)
+	default:
+		fmt.Fprintf(&g.body, "func (c *Client) %s(%s) (*%s, error) {\nvar out %s\nif err := c.Do(ctx, %s, %q, %s, &out); err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n\n", name, params, output, output, httpMethod, path, in)
+	}
+	return nil
+}
+
+var httpMethods = map[string]string{
+	http.MethodGet:     "http.MethodGet",
+	http.MethodHead:    "http.MethodHead",
+	http.MethodPost:    "http.MethodPost",
+	http.MethodPut:     "http.MethodPut",
+	http.MethodPatch:   "http.MethodPatch",
+	http.MethodDelete:  "http.MethodDelete",
+	http.MethodOptions: "http.MethodOptions",
+}
+
+// resolve 展开 $ref
+func (g *generator) resolve(s *specSchema) *specSchema {
+	for s != nil && s.Ref != "" {
+		s = g.doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// goType top 为 true 时生成 components 的定义，否则命名的 schema 使用类型名
+func (g *generator) goType(s *specSchema, top bool) string {
+	if s.Ref != "" && !top {
+		if name, ok := g.names[strings.TrimPrefix(s.Ref, "#/components/schemas/")]; ok {
+			return name
+		}
+		return "any"
+	}
+	switch s.typ() {
+	case "boolean":
+		return "bool"
+	case "integer":
+		if s.Format == "int64" {
+			return "int64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.useTime = true
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "array":
+		if s.Items == nil {
+			return "[]any"
+		}
+		return "[]" + g.goType(s.Items, false)
+	case "object":
+		if len(s.Properties) == 0 {
+			var add specSchema
+			if len(s.AdditionalProperties) > 0 && json.Unmarshal(s.AdditionalProperties, &add) == nil {
+				return "map[string]" + g.goType(&add, false)
+			}
+			return "map[string]any"
+		}
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for _, prop := range sortedKeys(s.Properties) {
+			p := s.Properties[prop]
+			typ := g.goType(p, false)
+			if !slices.Contains(s.Required, prop) && p.Ref != "" {
+				typ = "*" + typ
+			}
+			fmt.Fprintf(&b, "%s %s `json:\"%s%s\"`", exportName(prop), typ, prop, omitempty(s.Required, prop))
+			if p.Description != "" {
+				b.WriteString(" // " + strings.ReplaceAll(p.Description, "\n", " "))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+	return "any"
+}
+
+func omitempty(required []string, name string) string {
+	if slices.Contains(required, name) {
+		return ""
+	}
+	return ",omitempty"
+}
+
+// 按 go 的命名习惯使用大写的缩写
+var initialisms = map[string]bool{
+	"api": true, "id": true, "ip": true, "json": true, "http": true, "https": true, "uid": true,
+	"url": true, "uri": true, "uuid": true, "sql": true, "html": true, "xml": true, "ttl": true,
+}
+
+// exportName 转换为导出的 go 标识符，如 get_users_by_id 转换为 GetUsersByID
+func exportName(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	out := b.String()
+	if out != "" && unicode.IsDigit([]rune(out)[0]) {
+		out = "N" + out
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -17,8 +17,12 @@ import (
 type G struct {
 	wg    sync.WaitGroup
 	trace Tracer
+	span  SpanFunc
 }
 
+// SpanFunc 任务开始时创建追踪 span，返回的函数在任务结束时调用
+type SpanFunc func(ctx context.Context, name string) (context.Context, func(err error))
+
 type Tracer interface {
 	Error(msg string, args ...any)
 }
@@ -30,6 +34,12 @@ func New(l Tracer) *G {
 	return &G{trace: l}
 }
 
+// WithSpan 为 GoRunContext 的任务创建追踪 span
+func (g *G) WithSpan(fn SpanFunc) *G {
+	g.span = fn
+	return g
+}
+
 func (g *G) Wait() {
 	g.wg.Wait()
 }
@@ -65,6 +75,29 @@ func (g *G) GoRun(fn func()) {
 	}()
 }
 
+// GoRunContext 异步执行任务，设置了 WithSpan 时为任务创建 span
+// panic 会被记录，并标记 span 失败
+func (g *G) GoRunContext(ctx context.Context, name string, fn func(ctx context.Context)) {
+	end := func(error) {}
+	if g.span != nil {
+		ctx, end = g.span(ctx, name)
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				err := fmt.Errorf("PANIC[%v] TRACE[%s]", err, debug.Stack())
+				g.trace.Error(err.Error())
+				end(err)
+				return
+			}
+			end(nil)
+		}()
+		fn(ctx)
+	}()
+}
+
 type DefaultTracer struct{}
 
 func (DefaultTracer) Error(msg string, args ...any) {
@@ -0,0 +1,455 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ixugo/goweb/pkg/conc"
+	"github.com/ixugo/goweb/pkg/orm"
+	"github.com/ixugo/goweb/pkg/web"
+)
+
+// Handler 执行任务，通过 progress 上报进度，返回值以 json 保存到 Job.Result
+// ctx 在任务被取消或 Manager 停止时取消
+type Handler func(ctx context.Context, j *Job, progress func(web.Chunk)) (any, error)
+
+// Config 零值字段使用默认值
+type Config struct {
+	Workers       int           // 同时执行的任务数，默认 4
+	Queue         int           // 排队的任务数，默认 1024
+	FlushInterval time.Duration // 进度写入存储的最小间隔，默认 1s
+}
+
+// ErrInterrupted 服务停止时未完成的任务
+var ErrInterrupted = errors.New("服务停止，任务中断")
+
+// Manager 提交、执行、查询、取消任务
+// 任务状态保存在 Storer 中，进度变化推送给 Watch 的订阅者，客户端断线后可按任务 ID 重新订阅
+// 仅支持单实例执行，启动时将上次未结束的执行中任务标记为失败，排队中的任务重新入队
+/*
+	使用案例
+
+	m := job.NewManager(job.NewDB(db).AutoMigrate(true), job.Config{})
+	m.Register("export_users", func(ctx context.Context, j *job.Job, progress func(web.Chunk)) (any, error) {
+		...
+		progress(web.Chunk{Total: 100, Current: 1, Success: 1})
+		return gin.H{"url": "/files/users.csv"}, nil
+	})
+	if err := m.Start(context.Background()); err != nil {...}
+	defer m.Stop(context.Background())
+
+	j, err := m.Submit(ctx, "export_users", uid, in)
+*/
+type Manager struct {
+	store    Storer
+	cfg      Config
+	handlers map[string]Handler
+	queue    chan string
+	g        *conc.G
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu     sync.Mutex
+	states map[string]*state
+}
+
+// state 本实例中未结束任务的进度与订阅者
+type state struct {
+	mu       sync.Mutex
+	chunk    web.Chunk
+	saved    time.Time
+	cancel   context.CancelFunc
+	canceled bool
+	watchers map[chan web.Chunk]struct{}
+	done     chan struct{}
+}
+
+// NewManager ...
+func NewManager(store Storer, cfg Config) *Manager {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.Queue <= 0 {
+		cfg.Queue = 1024
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		store:    store,
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+		queue:    make(chan string, cfg.Queue),
+		g:        conc.New(nil),
+		ctx:      ctx,
+		cancel:   cancel,
+		states:   make(map[string]*state),
+	}
+}
+
+// Register 注册任务类型，需在 Start 之前调用
+func (m *Manager) Register(kind string, fn Handler) {
+	m.handlers[kind] = fn
+}
+
+// resumePageSize Start 分页读取未结束任务的每页数量
+const resumePageSize = 100
+
+// Start 恢复上次未结束的任务并启动 worker
+// 排队中的任务按创建时间顺序重新入队，超出队列容量的部分在后台等待入队
+func (m *Manager) Start(ctx context.Context) error {
+	// 标记为失败后不再属于 running，每次读取第一页直到为空
+	for {
+		running, _, err := m.store.Find(ctx, FindJobInput{PagerFilter: web.PagerFilter{Size: resumePageSize}, Status: StatusRunning})
+		if err != nil {
+			return err
+		}
+		if len(running) == 0 {
+			break
+		}
+		for _, j := range running {
+			if _, err := m.store.Transition(ctx, j.ID, []Status{StatusRunning}, finishChanges(StatusFailed, nil, ErrInterrupted)); err != nil {
+				return err
+			}
+		}
+	}
+	// worker 启动前读取全部排队中的任务，避免执行过程中状态变化导致分页错位
+	var pending []string
+	for page := 1; ; page++ {
+		jobs, _, err := m.store.Find(ctx, FindJobInput{PagerFilter: web.PagerFilter{Page: page, Size: resumePageSize, Sort: SortCreatedAtAsc}, Status: StatusPending})
+		if err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			pending = append(pending, j.ID)
+		}
+		if len(jobs) < resumePageSize {
+			break
+		}
+	}
+	for _, id := range pending {
+		m.newState(id)
+	}
+
+	for range m.cfg.Workers {
+		m.g.GoRunContext(m.ctx, "job.worker", m.work)
+	}
+	if len(pending) > 0 {
+		// 服务停止时未入队的任务保持排队中，下次启动时恢复
+		m.g.GoRunContext(m.ctx, "job.resume", func(ctx context.Context) {
+			for _, id := range pending {
+				select {
+				case <-ctx.Done():
+					return
+				case m.queue <- id:
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// Stop 取消执行中的任务并等待 worker 退出
+func (m *Manager) Stop(ctx context.Context) error {
+	m.cancel()
+	return m.g.UnsafeWaitWithContext(ctx)
+}
+
+// Submit 提交任务，payload 以 json 保存，通过 Job.Bind 读取
+func (m *Manager) Submit(ctx context.Context, kind string, owner int, payload any) (*Job, error) {
+	if _, ok := m.handlers[kind]; !ok {
+		return nil, web.ErrBadRequest.Withf("未知的任务类型 %s", kind)
+	}
+	if len(m.queue) >= cap(m.queue) {
+		return nil, web.ErrTooManyRequests.With("任务排队已满")
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, web.ErrJSON.With(err.Error())
+	}
+	j := Job{
+		ModelWithStrID: orm.NewModelWithStrID(uuid.NewString()),
+		Kind:           kind,
+		Owner:          owner,
+		Status:         StatusPending,
+		Payload:        string(b),
+	}
+	if err := m.store.Add(ctx, &j); err != nil {
+		return nil, web.ErrDB.Withf("job add err[%s]", err)
+	}
+	m.newState(j.ID)
+	select {
+	case m.queue <- j.ID:
+	default:
+		_, _ = m.store.Transition(ctx, j.ID, []Status{StatusPending}, finishChanges(StatusFailed, nil, errors.New("任务排队已满")))
+		m.finish(j.ID, web.Chunk{Err: "任务排队已满"})
+		return nil, web.ErrTooManyRequests.With("任务排队已满")
+	}
+	return &j, nil
+}
+
+// Get 查询任务
+func (m *Manager) Get(ctx context.Context, id string) (*Job, error) {
+	return m.store.Get(ctx, id)
+}
+
+// Find 任务列表
+func (m *Manager) Find(ctx context.Context, in FindJobInput) ([]*Job, int64, error) {
+	return m.store.Find(ctx, in)
+}
+
+// Cancel 取消排队或执行中的任务
+func (m *Manager) Cancel(ctx context.Context, id string) error {
+	ok, err := m.store.Transition(ctx, id, []Status{StatusPending}, finishChanges(StatusCanceled, nil, nil))
+	if err != nil {
+		return err
+	}
+	if ok {
+		m.finish(id, web.Chunk{Err: string(StatusCanceled)})
+		return nil
+	}
+
+	m.mu.Lock()
+	st, ok := m.states[id]
+	m.mu.Unlock()
+	if ok {
+		st.mu.Lock()
+		st.canceled = true
+		if st.cancel != nil {
+			st.cancel()
+		}
+		st.mu.Unlock()
+		return nil
+	}
+
+	j, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if j.Status.Done() {
+		return web.ErrBadRequest.Msg("任务已结束")
+	}
+	return web.ErrBadRequest.Msg("任务不在当前实例执行")
+}
+
+// Watch 订阅任务进度，先推送当前进度，任务结束或 ctx 取消后关闭
+// 只保留最新的进度，消费慢时跳过中间值
+// 与 web.SendChunk 配合使用时，进度为零值的任务在结束前不推送
+func (m *Manager) Watch(ctx context.Context, id string) (<-chan web.Chunk, error) {
+	m.mu.Lock()
+	st, ok := m.states[id]
+	var ch chan web.Chunk
+	if ok {
+		ch = make(chan web.Chunk, 1)
+		st.mu.Lock()
+		if st.chunk != (web.Chunk{}) {
+			ch <- st.chunk
+		}
+		st.watchers[ch] = struct{}{}
+		st.mu.Unlock()
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		// 已结束或不在当前实例，推送保存的进度
+		j, err := m.store.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		ch := make(chan web.Chunk, 1)
+		ch <- j.Chunk()
+		close(ch)
+		return ch, nil
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-st.done:
+		}
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if _, ok := st.watchers[ch]; ok {
+			delete(st.watchers, ch)
+			close(ch)
+		}
+	}()
+	return ch, nil
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(ctx, id)
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, id string) {
+	ok, err := m.store.Transition(ctx, id, []Status{StatusPending}, map[string]any{
+		"status":     StatusRunning,
+		"started_at": orm.Now(),
+	})
+	if err != nil {
+		slog.Error("job start", "id", id, "err", err)
+		m.finish(id, web.Chunk{Err: err.Error()})
+		return
+	}
+	if !ok {
+		// 已取消
+		m.finish(id, web.Chunk{Err: string(StatusCanceled)})
+		return
+	}
+	j, err := m.store.Get(ctx, id)
+	if err != nil {
+		slog.Error("job get", "id", id, "err", err)
+		return
+	}
+
+	st := m.getState(id)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	st.mu.Lock()
+	st.cancel = cancel
+	if st.canceled {
+		cancel()
+	}
+	st.mu.Unlock()
+
+	result, err := m.call(ctx, j, func(c web.Chunk) { m.progress(id, st, c) })
+
+	st.mu.Lock()
+	chunk, canceled := st.chunk, st.canceled
+	st.mu.Unlock()
+	status := StatusSucceeded
+	switch {
+	case canceled:
+		status, err = StatusCanceled, nil
+	case err == nil:
+	case m.ctx.Err() != nil:
+		status, err = StatusFailed, ErrInterrupted
+	default:
+		status = StatusFailed
+	}
+	changes := finishChanges(status, result, err)
+	changes["total"], changes["current"], changes["success"], changes["failure"] = chunk.Total, chunk.Current, chunk.Success, chunk.Failure
+	// 服务停止时 ctx 已取消，仍需记录结果
+	if _, err := m.store.Transition(context.WithoutCancel(ctx), id, []Status{StatusRunning}, changes); err != nil {
+		slog.Error("job finish", "id", id, "err", err)
+	}
+	switch status {
+	case StatusCanceled:
+		chunk.Err = string(StatusCanceled)
+	case StatusFailed:
+		chunk.Err = err.Error()
+	}
+	m.finish(id, chunk)
+}
+
+// call 执行任务，panic 视为失败
+func (m *Manager) call(ctx context.Context, j *Job, progress func(web.Chunk)) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("job panic", "id", j.ID, "kind", j.Kind, "err", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	fn, ok := m.handlers[j.Kind]
+	if !ok {
+		return nil, fmt.Errorf("未知的任务类型 %s", j.Kind)
+	}
+	return fn(ctx, j, progress)
+}
+
+func (m *Manager) progress(id string, st *state, c web.Chunk) {
+	st.mu.Lock()
+	st.chunk = c
+	for ch := range st.watchers {
+		push(ch, c)
+	}
+	save := time.Since(st.saved) >= m.cfg.FlushInterval
+	if save {
+		st.saved = time.Now()
+	}
+	st.mu.Unlock()
+	if save {
+		if err := m.store.SaveProgress(m.ctx, id, c); err != nil {
+			slog.Error("job save progress", "id", id, "err", err)
+		}
+	}
+}
+
+// push 丢弃未读取的旧进度
+func push(ch chan web.Chunk, c web.Chunk) {
+	select {
+	case ch <- c:
+	default:
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- c
+	}
+}
+
+func (m *Manager) newState(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[id] = &state{watchers: make(map[chan web.Chunk]struct{}), done: make(chan struct{})}
+}
+
+func (m *Manager) getState(id string) *state {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.states[id]
+	if !ok {
+		st = &state{watchers: make(map[chan web.Chunk]struct{}), done: make(chan struct{})}
+		m.states[id] = st
+	}
+	return st
+}
+
+// finish 推送最终进度并关闭订阅
+func (m *Manager) finish(id string, c web.Chunk) {
+	m.mu.Lock()
+	st, ok := m.states[id]
+	delete(m.states, id)
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for ch := range st.watchers {
+		push(ch, c)
+		delete(st.watchers, ch)
+		close(ch)
+	}
+	close(st.done)
+}
+
+func finishChanges(status Status, result any, err error) map[string]any {
+	changes := map[string]any{
+		"status":      status,
+		"finished_at": orm.Now(),
+	}
+	if result != nil {
+		b, _ := json.Marshal(result)
+		changes["result"] = string(b)
+	}
+	if err != nil {
+		changes["error"] = err.Error()
+	}
+	return changes
+}
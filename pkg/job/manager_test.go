@@ -0,0 +1,210 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/ixugo/goweb/pkg/orm"
+	"github.com/ixugo/goweb/pkg/web"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestStore(t *testing.T) DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	require.NoError(t, err)
+	return NewDB(db).AutoMigrate(true)
+}
+
+func waitStatus(t *testing.T, m *Manager, id string, status Status) *Job {
+	var j *Job
+	require.Eventually(t, func() bool {
+		var err error
+		j, err = m.Get(context.Background(), id)
+		require.NoError(t, err)
+		return j.Status == status
+	}, 3*time.Second, 10*time.Millisecond)
+	return j
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(newTestStore(t), Config{Workers: 1, FlushInterval: time.Millisecond})
+	release := make(chan struct{})
+	m.Register("count", func(ctx context.Context, j *Job, progress func(web.Chunk)) (any, error) {
+		var in struct{ N int }
+		if err := j.Bind(&in); err != nil {
+			return nil, err
+		}
+		<-release
+		for i := 1; i <= in.N; i++ {
+			progress(web.Chunk{Total: in.N, Current: i, Success: i})
+		}
+		return map[string]int{"n": in.N}, nil
+	})
+	m.Register("block", func(ctx context.Context, _ *Job, _ func(web.Chunk)) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	m.Register("panic", func(context.Context, *Job, func(web.Chunk)) (any, error) {
+		panic("boom")
+	})
+	require.NoError(t, m.Start(ctx))
+	defer func() { require.NoError(t, m.Stop(ctx)) }()
+
+	_, err := m.Submit(ctx, "unknown", 1, nil)
+	require.ErrorIs(t, err, web.ErrBadRequest)
+
+	// 成功，订阅者收到进度直到结束
+	j, err := m.Submit(ctx, "count", 1, map[string]int{"N": 3})
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, j.Status)
+	ch, err := m.Watch(ctx, j.ID)
+	require.NoError(t, err)
+	// 排队中的任务等待 count 执行完成
+	pending, err := m.Submit(ctx, "count", 2, map[string]int{"N": 1})
+	require.NoError(t, err)
+	require.NoError(t, m.Cancel(ctx, pending.ID))
+	require.Equal(t, StatusCanceled, waitStatus(t, m, pending.ID, StatusCanceled).Status)
+
+	close(release)
+	var last web.Chunk
+	for c := range ch {
+		last = c
+	}
+	require.Equal(t, web.Chunk{Total: 3, Current: 3, Success: 3}, last)
+	got := waitStatus(t, m, j.ID, StatusSucceeded)
+	require.Equal(t, `{"n":3}`, got.Result)
+	require.Equal(t, 3, got.Success)
+	require.NotNil(t, got.StartedAt)
+	require.NotNil(t, got.FinishedAt)
+
+	// 已结束的任务推送保存的进度
+	ch, err = m.Watch(ctx, j.ID)
+	require.NoError(t, err)
+	require.Equal(t, web.Chunk{Total: 3, Current: 3, Success: 3}, <-ch)
+	_, ok := <-ch
+	require.False(t, ok)
+	require.ErrorIs(t, m.Cancel(ctx, j.ID), web.ErrBadRequest)
+
+	// 取消执行中的任务
+	j, err = m.Submit(ctx, "block", 1, nil)
+	require.NoError(t, err)
+	waitStatus(t, m, j.ID, StatusRunning)
+	require.NoError(t, m.Cancel(ctx, j.ID))
+	require.Empty(t, waitStatus(t, m, j.ID, StatusCanceled).Error)
+
+	// panic 视为失败
+	j, err = m.Submit(ctx, "panic", 1, nil)
+	require.NoError(t, err)
+	require.Equal(t, "panic: boom", waitStatus(t, m, j.ID, StatusFailed).Error)
+
+	jobs, total, err := m.Find(ctx, FindJobInput{Owner: 1, Status: StatusCanceled})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, "block", jobs[0].Kind)
+}
+
+func TestManagerRecover(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	require.NoError(t, store.Add(ctx, &Job{ModelWithStrID: orm.NewModelWithStrID("running"), Kind: "echo", Status: StatusRunning}))
+	require.NoError(t, store.Add(ctx, &Job{ModelWithStrID: orm.NewModelWithStrID("pending"), Kind: "echo", Status: StatusPending, Payload: `"hi"`}))
+
+	m := NewManager(store, Config{})
+	m.Register("echo", func(_ context.Context, j *Job, _ func(web.Chunk)) (any, error) {
+		var s string
+		return s, j.Bind(&s)
+	})
+	require.NoError(t, m.Start(ctx))
+	defer func() { require.NoError(t, m.Stop(ctx)) }()
+
+	require.Equal(t, ErrInterrupted.Error(), waitStatus(t, m, "running", StatusFailed).Error)
+	require.Equal(t, `"hi"`, waitStatus(t, m, "pending", StatusSucceeded).Result)
+}
+
+// 排队中的任务超过队列容量时 Start 不阻塞，并按创建时间顺序执行
+func TestManagerRecoverOverflow(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	now := time.Now()
+	ids := []string{"a", "b", "c"}
+	for i, id := range ids {
+		model := orm.NewModelWithStrID(id)
+		model.CreatedAt = orm.Time{Time: now.Add(time.Duration(i) * time.Second)}
+		require.NoError(t, store.Add(ctx, &Job{ModelWithStrID: model, Kind: "echo", Status: StatusPending}))
+	}
+
+	var mu sync.Mutex
+	var order []string
+	m := NewManager(store, Config{Workers: 1, Queue: 1})
+	m.Register("echo", func(_ context.Context, j *Job, _ func(web.Chunk)) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, j.ID)
+		return nil, nil
+	})
+	done := make(chan error, 1)
+	go func() { done <- m.Start(ctx) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("start blocked")
+	}
+	defer func() { require.NoError(t, m.Stop(ctx)) }()
+
+	for _, id := range ids {
+		waitStatus(t, m, id, StatusSucceeded)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, ids, order)
+}
+
+func TestManagerStream(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(newTestStore(t), Config{FlushInterval: time.Millisecond})
+	start := make(chan struct{})
+	m.Register("fail", func(_ context.Context, _ *Job, progress func(web.Chunk)) (any, error) {
+		<-start
+		progress(web.Chunk{Total: 2, Current: 1, Failure: 1})
+		return nil, errors.New("bad row")
+	})
+	require.NoError(t, m.Start(ctx))
+	defer func() { require.NoError(t, m.Stop(ctx)) }()
+
+	r := gin.New()
+	r.GET("/jobs/:id/progress", func(c *gin.Context) { m.SendChunk(c, c.Param("id")) })
+	r.GET("/jobs/:id/events", func(c *gin.Context) { m.ServeSSE(c, c.Param("id")) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/none/progress", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	j, err := m.Submit(ctx, "fail", 1, nil)
+	require.NoError(t, err)
+	close(start)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID+"/progress", nil))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Equal(t, `{"total":2,"current":1,"success":0,"failure":1,"err":"bad row"}`, lines[len(lines)-1])
+
+	// 重连后仍可获取结果
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID+"/events", nil))
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	require.Contains(t, body, "event: progress\ndata: {\"total\":2,\"current\":1,\"success\":0,\"failure\":1,\"err\":\"bad row\"}\n\n")
+	require.Contains(t, body, "event: done\ndata: {")
+	require.Contains(t, body, `"status":"failed"`)
+}
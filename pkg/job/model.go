@@ -0,0 +1,74 @@
+package job
+
+import (
+	"encoding/json"
+
+	"github.com/ixugo/goweb/pkg/orm"
+	"github.com/ixugo/goweb/pkg/web"
+)
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // 排队中
+	StatusRunning   Status = "running"   // 执行中
+	StatusSucceeded Status = "succeeded" // 成功
+	StatusFailed    Status = "failed"    // 失败
+	StatusCanceled  Status = "canceled"  // 已取消
+)
+
+// Done 是否已结束
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCanceled
+}
+
+// Job 后台任务
+type Job struct {
+	orm.ModelWithStrID
+	Kind       string    `gorm:"notNull;index;default:'';comment:任务类型" json:"kind"`
+	Owner      int       `gorm:"notNull;index;default:0;comment:提交者" json:"owner"`
+	Status     Status    `gorm:"notNull;index;default:'';comment:状态" json:"status"`
+	Payload    string    `gorm:"notNull;default:'';comment:参数" json:"-"`
+	Result     string    `gorm:"notNull;default:'';comment:结果 json" json:"result,omitempty"`
+	Error      string    `gorm:"notNull;default:'';comment:失败原因" json:"error,omitempty"`
+	Total      int       `gorm:"notNull;default:0;comment:总数" json:"total"`
+	Current    int       `gorm:"notNull;default:0;comment:已处理" json:"current"`
+	Success    int       `gorm:"notNull;default:0;comment:成功数" json:"success"`
+	Failure    int       `gorm:"notNull;default:0;comment:失败数" json:"failure"`
+	StartedAt  *orm.Time `gorm:"comment:开始时间" json:"started_at"`
+	FinishedAt *orm.Time `gorm:"comment:结束时间" json:"finished_at"`
+}
+
+// TableName ...
+func (*Job) TableName() string {
+	return "jobs"
+}
+
+// Bind 解析提交时的参数
+func (j *Job) Bind(v any) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
+
+// Chunk 当前进度，失败或取消时 Err 不为空
+func (j *Job) Chunk() web.Chunk {
+	c := web.Chunk{Total: j.Total, Current: j.Current, Success: j.Success, Failure: j.Failure}
+	switch j.Status {
+	case StatusFailed:
+		c.Err = j.Error
+	case StatusCanceled:
+		c.Err = string(StatusCanceled)
+	}
+	return c
+}
+
+// SortCreatedAtAsc 按创建时间正序，FindJobInput 默认按创建时间倒序
+const SortCreatedAtAsc = "created_at"
+
+// FindJobInput 任务列表查询
+type FindJobInput struct {
+	web.PagerFilter
+	Kind   string `form:"kind"`
+	Status Status `form:"status" binding:"omitempty,oneof=pending running succeeded failed canceled"`
+	Owner  int    `form:"-"`
+}
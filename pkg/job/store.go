@@ -0,0 +1,97 @@
+package job
+
+import (
+	"context"
+
+	"github.com/ixugo/goweb/pkg/orm"
+	"github.com/ixugo/goweb/pkg/web"
+	"gorm.io/gorm"
+)
+
+// Storer 任务持久化
+type Storer interface {
+	Add(ctx context.Context, j *Job) error
+	Get(ctx context.Context, id string) (*Job, error)
+	Find(ctx context.Context, in FindJobInput) ([]*Job, int64, error)
+	// Transition 状态属于 from 时更新为 changes，返回是否更新成功
+	Transition(ctx context.Context, id string, from []Status, changes map[string]any) (bool, error)
+	// SaveProgress 保存执行中任务的进度
+	SaveProgress(ctx context.Context, id string, c web.Chunk) error
+}
+
+var _ Storer = DB{}
+
+// DB gorm 存储
+type DB struct {
+	db *gorm.DB
+}
+
+// NewDB ...
+func NewDB(db *gorm.DB) DB {
+	return DB{db: db}
+}
+
+// AutoMigrate ...
+func (d DB) AutoMigrate(ok bool) DB {
+	if !ok {
+		return d
+	}
+	if err := d.db.AutoMigrate(new(Job)); err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Add implements Storer.
+func (d DB) Add(ctx context.Context, j *Job) error {
+	return d.db.WithContext(ctx).Create(j).Error
+}
+
+// Get implements Storer.
+func (d DB) Get(ctx context.Context, id string) (*Job, error) {
+	var j Job
+	if err := orm.FirstWithContext(ctx, d.db, &j, orm.Where("id = ?", id)); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// Find implements Storer.
+func (d DB) Find(ctx context.Context, in FindJobInput) ([]*Job, int64, error) {
+	opts := make([]orm.QueryOption, 0, 4)
+	if in.Kind != "" {
+		opts = append(opts, orm.Where("kind = ?", in.Kind))
+	}
+	if in.Status != "" {
+		opts = append(opts, orm.Where("status = ?", in.Status))
+	}
+	if in.Owner > 0 {
+		opts = append(opts, orm.Where("owner = ?", in.Owner))
+	}
+	if in.Sort == SortCreatedAtAsc {
+		opts = append(opts, orm.OrderBy("created_at ASC, id ASC"))
+	} else {
+		opts = append(opts, orm.OrderBy("created_at DESC, id DESC"))
+	}
+	out := make([]*Job, 0, in.Limit())
+	total, err := orm.FindWithContext(ctx, d.db, &out, in, opts...)
+	return out, total, err
+}
+
+// Transition implements Storer.
+func (d DB) Transition(ctx context.Context, id string, from []Status, changes map[string]any) (bool, error) {
+	changes["updated_at"] = orm.Now()
+	tx := d.db.WithContext(ctx).Model(new(Job)).Where("id = ? AND status IN ?", id, from).Updates(changes)
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// SaveProgress implements Storer.
+func (d DB) SaveProgress(ctx context.Context, id string, c web.Chunk) error {
+	return d.db.WithContext(ctx).Model(new(Job)).Where("id = ? AND status = ?", id, StatusRunning).Updates(map[string]any{
+		"total":      c.Total,
+		"current":    c.Current,
+		"success":    c.Success,
+		"failure":    c.Failure,
+		"updated_at": orm.Now(),
+	}).Error
+}
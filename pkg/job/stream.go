@@ -0,0 +1,73 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ixugo/goweb/pkg/web"
+)
+
+// SendChunk 以 web.SendChunk 的分块协议推送进度，任务结束后返回
+// 断线后使用相同的任务 ID 重新请求即可继续
+func (m *Manager) SendChunk(c *gin.Context, id string) {
+	ch, err := m.Watch(c.Request.Context(), id)
+	if err != nil {
+		web.Fail(c, err)
+		return
+	}
+	web.SendChunk(ch, c)
+}
+
+// ServeSSE 以 text/event-stream 推送进度
+// 进度为 progress 事件，任务结束时发送 done 事件，数据为任务详情
+func (m *Manager) ServeSSE(c *gin.Context, id string) {
+	ch, err := m.Watch(c.Request.Context(), id)
+	if err != nil {
+		web.Fail(c, err)
+		return
+	}
+
+	rc := http.NewResponseController(c.Writer) // nolint
+	write := func(event string, v any) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		b, _ := json.Marshal(v)
+		if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, b); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	tick := time.NewTicker(15 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-tick.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case v, ok := <-ch:
+			if ok {
+				if !write("progress", v) {
+					return
+				}
+				continue
+			}
+			j, err := m.store.Get(c.Request.Context(), id)
+			if err != nil {
+				return
+			}
+			write("done", j)
+			return
+		}
+	}
+}
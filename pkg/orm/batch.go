@@ -0,0 +1,82 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FindInBatches 按主键升序分批查询，每批最多 size 条，fn 返回错误时停止
+// 使用主键作为游标，不使用 OFFSET，opts 中的排序与分页条件会被忽略，例如 QuerySpec.Options 添加的排序
+func FindInBatches[T any](ctx context.Context, db *gorm.DB, size int, fn func([]*T) error, opts ...QueryOption) error {
+	if size <= 0 {
+		size = 500
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return err
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return fmt.Errorf("%s 没有主键", stmt.Schema.Name)
+	}
+
+	db = db.Model(new(T)).WithContext(ctx)
+	for _, opt := range opts {
+		db = opt(db)
+	}
+	// 游标依赖主键升序，其它排序会导致漏掉数据
+	delete(db.Statement.Clauses, "ORDER BY")
+	delete(db.Statement.Clauses, "LIMIT")
+	order := clause.OrderByColumn{Column: clause.Column{Name: pk.DBName}}
+	var last any
+	for {
+		tx := db.Session(&gorm.Session{})
+		if last != nil {
+			tx = tx.Where(clause.Gt{Column: clause.Column{Name: pk.DBName}, Value: last})
+		}
+		batch := make([]*T, 0, size)
+		if err := tx.Order(order).Limit(size).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < size {
+			return nil
+		}
+		last, _ = pk.ValueOf(ctx, reflect.ValueOf(batch[len(batch)-1]))
+	}
+}
+
+// BatchWriter 分批写入的目标，web.Exporter 已实现
+type BatchWriter[T any] interface {
+	SetTotal(total int)
+	Write(items ...*T) error
+}
+
+// ExportBatches 统计总数后分批查询写入 w，用于 web.Export
+//
+//	web.Export(c, "users", func(e *web.Exporter[User]) error {
+//		return orm.ExportBatches(c.Request.Context(), db, e, 500, opts...)
+//	})
+func ExportBatches[T any](ctx context.Context, db *gorm.DB, w BatchWriter[T], size int, opts ...QueryOption) error {
+	count := db.Model(new(T)).WithContext(ctx)
+	for _, opt := range opts {
+		count = opt(count)
+	}
+	var total int64
+	if err := count.Count(&total).Error; err != nil {
+		return err
+	}
+	w.SetTotal(int(total))
+	return FindInBatches(ctx, db, size, func(batch []*T) error {
+		return w.Write(batch...)
+	}, opts...)
+}
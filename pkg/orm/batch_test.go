@@ -0,0 +1,80 @@
+package orm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type batchItem struct {
+	ID   int    `json:"id" export:"编号"`
+	Name string `json:"name" export:"名称"`
+	Kind int    `json:"kind"`
+}
+
+func TestFindInBatches(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(new(batchItem)))
+	items := make([]batchItem, 0, 25)
+	for i := 1; i <= 25; i++ {
+		items = append(items, batchItem{ID: i, Name: "n", Kind: i % 2})
+	}
+	require.NoError(t, db.Create(&items).Error)
+
+	ctx := context.Background()
+	var sizes []int
+	var ids []int
+	err = FindInBatches(ctx, db, 10, func(batch []*batchItem) error {
+		sizes = append(sizes, len(batch))
+		for _, v := range batch {
+			ids = append(ids, v.ID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{10, 10, 5}, sizes)
+	require.Len(t, ids, 25)
+	require.IsIncreasing(t, ids)
+
+	kind := func(d *gorm.DB) *gorm.DB { return d.Where("kind = ?", 1) }
+	var w batchWriter
+	require.NoError(t, ExportBatches(ctx, db, &w, 5, kind))
+	require.Equal(t, 13, w.total)
+	require.Equal(t, []int{5, 5, 3}, w.sizes)
+	require.Equal(t, 1, w.items[0].ID)
+	require.Equal(t, 25, w.items[12].ID)
+
+	// 忽略 opts 中的排序与分页，避免游标漏掉数据
+	desc := func(d *gorm.DB) *gorm.DB { return d.Order("kind DESC").Order("id DESC").Limit(3) }
+	ids = ids[:0]
+	err = FindInBatches(ctx, db, 10, func(batch []*batchItem) error {
+		for _, v := range batch {
+			ids = append(ids, v.ID)
+		}
+		return nil
+	}, desc)
+	require.NoError(t, err)
+	require.Len(t, ids, 25)
+	require.IsIncreasing(t, ids)
+}
+
+type batchWriter struct {
+	total int
+	sizes []int
+	items []*batchItem
+}
+
+func (w *batchWriter) SetTotal(total int) {
+	w.total = total
+}
+
+func (w *batchWriter) Write(items ...*batchItem) error {
+	w.sizes = append(w.sizes, len(items))
+	w.items = append(w.items, items...)
+	return nil
+}
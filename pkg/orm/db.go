@@ -14,6 +14,7 @@ import (
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
 )
 
 type Config struct {
@@ -23,32 +24,80 @@ type Config struct {
 	SlowThreshold   time.Duration
 }
 
-// type ORMLog struct {
-// 	*slog.Logger
-// }
-
-// func (o *ORMLog) LogMode(level logger.LogLevel) logger.Interface {
-// 	return o
-// }
-
-// func (o *ORMLog) Info(context.Context, string, ...interface{}) {
-// }
-// func (o *ORMLog) Warn(context.Context, string, ...interface{}) {
-// }
-// func (o *ORMLog) Error(context.Context, string, ...interface{}) {
-// }
-// func (o *ORMLog) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
-// }
-
 type Logger struct {
 	*slog.Logger
 	debug bool
 	slog  time.Duration
+
+	fromContext func(context.Context) (*slog.Logger, bool)
 }
 
 // NewLogger 封装日志
 func NewLogger(l *slog.Logger, debug bool, slow time.Duration) *Logger {
-	return &Logger{l, debug, slow}
+	return &Logger{Logger: l, debug: debug, slog: slow}
+}
+
+// WithContext 从 context 中获取请求日志，使 sql 日志携带 trace_id 等字段
+// 需要调用 db.WithContext(ctx)
+func (l *Logger) WithContext(fn func(context.Context) (*slog.Logger, bool)) *Logger {
+	l.fromContext = fn
+	return l
+}
+
+func (l *Logger) log(ctx context.Context) *slog.Logger {
+	if l.fromContext != nil && ctx != nil {
+		if v, ok := l.fromContext(ctx); ok {
+			return v
+		}
+	}
+	return l.Logger
+}
+
+var _ logger.Interface = (*Logger)(nil)
+
+// LogMode implements logger.Interface.
+func (l *Logger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+// Info implements logger.Interface.
+func (l *Logger) Info(ctx context.Context, msg string, v ...any) {
+	l.log(ctx).Info("gorm", "detail", fmt.Sprintf(msg, v...))
+}
+
+// Warn implements logger.Interface.
+func (l *Logger) Warn(ctx context.Context, msg string, v ...any) {
+	l.log(ctx).Warn("gorm", "detail", fmt.Sprintf(msg, v...))
+}
+
+// Error implements logger.Interface.
+func (l *Logger) Error(ctx context.Context, msg string, v ...any) {
+	l.log(ctx).Error("gorm", "detail", fmt.Sprintf(msg, v...))
+}
+
+// Trace implements logger.Interface.
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	since := time.Since(begin)
+	isErr := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	isSlow := l.slog > 0 && since >= l.slog
+	if !isErr && !isSlow && !l.debug {
+		return
+	}
+	sql, rows := fc()
+	args := []any{
+		"file", utils.FileWithLineNum(),
+		"sql", sql,
+		"rows", rows,
+		"since", since.Milliseconds(),
+	}
+	switch {
+	case isErr:
+		l.log(ctx).Error("gorm", append(args, "err", err)...)
+	case isSlow:
+		l.log(ctx).Warn("gorm slow sql", args...)
+	default:
+		l.log(ctx).Debug("gorm", args...)
+	}
 }
 
 func (l *Logger) Printf(format string, v ...interface{}) {
@@ -94,6 +143,10 @@ func New(debug bool, dialector gorm.Dialector, cfg Config, w logger.Writer) (*go
 		SlowThreshold: cfg.SlowThreshold,
 		LogLevel:      level,
 	})
+	// 支持 context 的日志直接使用
+	if v, ok := w.(logger.Interface); ok {
+		l = v
+	}
 
 	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger:         l,
@@ -0,0 +1,255 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 过滤操作符，参数格式为 filter[name]=op:value，省略 op 时为 eq
+const (
+	OpEq    = "eq"
+	OpNe    = "ne"
+	OpGt    = "gt"
+	OpGte   = "gte"
+	OpLt    = "lt"
+	OpLte   = "lte"
+	OpLike  = "like" // 包含，% 与 _ 按普通字符处理
+	OpIn    = "in"   // 逗号分隔，如 in:1,2
+	OpNotIn = "nin"
+	OpNull  = "null" // null:true 为空，null:false 不为空
+)
+
+// 单个 in 条件最多的值
+const maxFilterValues = 100
+
+var filterOps = []string{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike, OpIn, OpNotIn, OpNull}
+
+// ErrInvalidQuery 过滤、排序或游标参数不合法，由调用方转换为 400 响应
+var ErrInvalidQuery = errors.New("invalid query")
+
+// queryError 携带具体原因，errors.Is(err, ErrInvalidQuery) 为 true
+type queryError struct {
+	msg string
+}
+
+func (e *queryError) Error() string {
+	return e.msg
+}
+
+func (e *queryError) Is(target error) bool {
+	return target == ErrInvalidQuery
+}
+
+func invalidQuery(format string, args ...any) error {
+	return &queryError{msg: fmt.Sprintf(format, args...)}
+}
+
+// ListFilter 列表查询的过滤与排序参数，web.PagerFilter 已实现
+type ListFilter interface {
+	// Filters 过滤条件，参数名对应 op:value
+	Filters() map[string]string
+	// SortBy 排序，多个字段使用逗号分隔，负号表示倒序，如 -created_at,name
+	SortBy() string
+}
+
+type filterField struct {
+	column string
+	ops    []string
+}
+
+// QuerySpec 列表接口允许过滤与排序的字段，通常每个模型定义一个
+//
+//	var userQuery = orm.NewQuerySpec().
+//		Filter("status", orm.OpEq, orm.OpIn).
+//		Filter("name", orm.OpLike).
+//		Sort("created_at", "name").
+//		DefaultSort("-created_at")
+type QuerySpec struct {
+	filters     map[string]filterField
+	sorts       map[string]string
+	defaultSort string
+}
+
+// NewQuerySpec ...
+func NewQuerySpec() *QuerySpec {
+	return &QuerySpec{filters: make(map[string]filterField), sorts: make(map[string]string)}
+}
+
+// Filter 允许过滤的字段，参数名即列名，ops 为空时仅允许 eq
+func (s *QuerySpec) Filter(name string, ops ...string) *QuerySpec {
+	return s.FilterColumn(name, name, ops...)
+}
+
+// FilterColumn 参数名与列名不同时使用
+func (s *QuerySpec) FilterColumn(name, column string, ops ...string) *QuerySpec {
+	if len(ops) == 0 {
+		ops = []string{OpEq}
+	}
+	for _, op := range ops {
+		if !slices.Contains(filterOps, op) {
+			panic("orm: unknown filter op " + op)
+		}
+	}
+	s.filters[name] = filterField{column: column, ops: ops}
+	return s
+}
+
+// Sort 允许排序的字段，参数名即列名
+func (s *QuerySpec) Sort(names ...string) *QuerySpec {
+	for _, name := range names {
+		s.sorts[name] = name
+	}
+	return s
+}
+
+// SortColumn 参数名与列名不同时使用
+func (s *QuerySpec) SortColumn(name, column string) *QuerySpec {
+	s.sorts[name] = column
+	return s
+}
+
+// DefaultSort 未指定排序时使用，格式与 ListFilter.SortBy 相同
+func (s *QuerySpec) DefaultSort(sort string) *QuerySpec {
+	s.defaultSort = sort
+	return s
+}
+
+// SortSafelist 允许排序的参数，包含倒序，可赋值给 PagerFilter.SortSafelist 用于 FindScroll
+func (s *QuerySpec) SortSafelist() []string {
+	out := make([]string, 0, len(s.sorts)*2)
+	for name := range s.sorts {
+		out = append(out, name, "-"+name)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Options 将过滤与排序参数转换为查询条件，不允许的字段或操作符返回 ErrInvalidQuery
+func (s *QuerySpec) Options(f ListFilter) ([]QueryOption, error) {
+	where, err := s.Where(f)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.Order(f)
+	if err != nil {
+		return nil, err
+	}
+	return append(where, order...), nil
+}
+
+// Where 将过滤参数转换为查询条件
+func (s *QuerySpec) Where(f ListFilter) ([]QueryOption, error) {
+	filters := f.Filters()
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	// 保证生成的 sql 稳定
+	slices.Sort(names)
+
+	out := make([]QueryOption, 0, len(names))
+	for _, name := range names {
+		field, ok := s.filters[name]
+		if !ok {
+			return nil, invalidQuery("%s 不支持过滤", name)
+		}
+		op, value := parseFilter(filters[name])
+		if !slices.Contains(field.ops, op) {
+			return nil, invalidQuery("%s 不支持 %s 过滤", name, op)
+		}
+		expr, err := filterExpr(clause.Column{Name: field.column}, op, value)
+		if err != nil {
+			return nil, invalidQuery("%s %s", name, err)
+		}
+		out = append(out, func(d *gorm.DB) *gorm.DB {
+			return d.Where(expr)
+		})
+	}
+	return out, nil
+}
+
+// Order 将排序参数转换为排序条件，为空时使用 DefaultSort
+func (s *QuerySpec) Order(f ListFilter) ([]QueryOption, error) {
+	sort := f.SortBy()
+	if strings.TrimSpace(sort) == "" {
+		sort = s.defaultSort
+	}
+	var columns []clause.OrderByColumn
+	for _, v := range strings.Split(sort, ",") {
+		v = strings.TrimSpace(v)
+		name := strings.TrimLeft(v, "-+")
+		if name == "" {
+			continue
+		}
+		column, ok := s.sorts[name]
+		if !ok {
+			return nil, invalidQuery("%s 不支持排序", name)
+		}
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: strings.HasPrefix(v, "-")})
+	}
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	return []QueryOption{func(d *gorm.DB) *gorm.DB {
+		return d.Order(clause.OrderBy{Columns: columns})
+	}}, nil
+}
+
+// parseFilter 解析 op:value，op 不合法时整体作为 eq 的值，如 12:00
+func parseFilter(s string) (op, value string) {
+	if op, value, ok := strings.Cut(s, ":"); ok && slices.Contains(filterOps, op) {
+		return op, value
+	}
+	return OpEq, s
+}
+
+// 使用 ! 转义，mysql 中 '\' 会被当作未结束的字符串
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+func filterExpr(col clause.Column, op, value string) (clause.Expression, error) {
+	switch op {
+	case OpEq:
+		return clause.Eq{Column: col, Value: value}, nil
+	case OpNe:
+		return clause.Neq{Column: col, Value: value}, nil
+	case OpGt:
+		return clause.Gt{Column: col, Value: value}, nil
+	case OpGte:
+		return clause.Gte{Column: col, Value: value}, nil
+	case OpLt:
+		return clause.Lt{Column: col, Value: value}, nil
+	case OpLte:
+		return clause.Lte{Column: col, Value: value}, nil
+	case OpLike:
+		return clause.Expr{SQL: `? LIKE ? ESCAPE '!'`, Vars: []any{col, "%" + likeEscaper.Replace(value) + "%"}}, nil
+	case OpIn, OpNotIn:
+		values := strings.Split(value, ",")
+		if len(values) > maxFilterValues {
+			return nil, fmt.Errorf("最多 %d 个值", maxFilterValues)
+		}
+		vars := make([]any, len(values))
+		for i, v := range values {
+			vars[i] = v
+		}
+		if op == OpNotIn {
+			return clause.Not(clause.IN{Column: col, Values: vars}), nil
+		}
+		return clause.IN{Column: col, Values: vars}, nil
+	case OpNull:
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("null 的值须为 true 或 false")
+		}
+		if isNull {
+			return clause.Eq{Column: col, Value: nil}, nil
+		}
+		return clause.Neq{Column: col, Value: nil}, nil
+	}
+	return nil, fmt.Errorf("不支持 %s", op)
+}
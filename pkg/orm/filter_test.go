@@ -0,0 +1,124 @@
+package orm
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// pagerFilter 测试用的分页参数，与 pagerFilter 行为一致
+type pagerFilter struct {
+	Size         int
+	Sort         string
+	Next         string
+	Filter       map[string]string
+	SortSafelist []string
+}
+
+func (f pagerFilter) Offset() int                { return 0 }
+func (f pagerFilter) Limit() int                 { return max(f.Size, 10) }
+func (f pagerFilter) Cursor() string             { return f.Next }
+func (f pagerFilter) Filters() map[string]string { return f.Filter }
+func (f pagerFilter) SortBy() string             { return f.Sort }
+func (f pagerFilter) MustSortColumn() string     { return strings.TrimLeft(f.Sort, "-") }
+
+func (f pagerFilter) SortColumn() (string, error) {
+	if !slices.Contains(f.SortSafelist, f.Sort) {
+		return "", fmt.Errorf("%s 不支持排序", f.Sort)
+	}
+	return f.MustSortColumn(), nil
+}
+
+func (f pagerFilter) SortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+type filterItem struct {
+	ID     int
+	Name   string
+	Status int
+	Remark *string
+}
+
+func TestQuerySpec(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(new(filterItem)))
+	remark := "r"
+	items := []filterItem{
+		{ID: 1, Name: "foo", Status: 1},
+		{ID: 2, Name: "foobar", Status: 2, Remark: &remark},
+		{ID: 3, Name: "bar", Status: 3},
+		{ID: 4, Name: "50%_off", Status: 1},
+		{ID: 5, Name: "bar", Status: 2},
+	}
+	require.NoError(t, db.Create(&items).Error)
+
+	spec := NewQuerySpec().
+		Filter("status", OpEq, OpIn, OpNotIn, OpGte).
+		Filter("name", OpEq, OpLike).
+		Filter("remark", OpNull).
+		FilterColumn("state", "status").
+		Sort("name", "status").
+		SortColumn("no", "id").
+		DefaultSort("-no")
+	require.Equal(t, []string{"-name", "-no", "-status", "name", "no", "status"}, spec.SortSafelist())
+
+	find := func(f pagerFilter) ([]int, error) {
+		opts, err := spec.Options(f)
+		if err != nil {
+			return nil, err
+		}
+		var out []*filterItem
+		if _, err := Find(db, &out, f, opts...); err != nil {
+			return nil, err
+		}
+		ids := make([]int, 0, len(out))
+		for _, v := range out {
+			ids = append(ids, v.ID)
+		}
+		return ids, nil
+	}
+
+	cases := []struct {
+		sort   string
+		filter map[string]string
+		want   []int
+	}{
+		{"", nil, []int{5, 4, 3, 2, 1}},
+		{"name,-no", nil, []int{4, 5, 3, 1, 2}},
+		{"-status,no", nil, []int{3, 2, 5, 1, 4}},
+		{"no", map[string]string{"status": "in:1,2"}, []int{1, 2, 4, 5}},
+		{"no", map[string]string{"status": "nin:1,2"}, []int{3}},
+		{"no", map[string]string{"status": "gte:2", "name": "bar"}, []int{3, 5}},
+		{"no", map[string]string{"state": "1"}, []int{1, 4}},
+		{"no", map[string]string{"name": "like:foo"}, []int{1, 2}},
+		{"no", map[string]string{"name": "like:%_"}, []int{4}},
+		{"no", map[string]string{"remark": "null:false"}, []int{2}},
+	}
+	for _, tc := range cases {
+		ids, err := find(pagerFilter{Size: 10, Sort: tc.sort, Filter: tc.filter})
+		require.NoError(t, err, tc)
+		require.Equal(t, tc.want, ids, tc)
+	}
+
+	for _, f := range []pagerFilter{
+		{Sort: "remark"},
+		{Filter: map[string]string{"id": "1"}},
+		{Filter: map[string]string{"name": "in:a,b"}},
+		{Filter: map[string]string{"remark": "null:x"}},
+	} {
+		_, err := spec.Options(f)
+		require.ErrorIs(t, err, ErrInvalidQuery, f)
+	}
+}
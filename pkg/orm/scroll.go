@@ -0,0 +1,255 @@
+package orm
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ScrollFilter 滚动翻页参数，web.PagerFilter 已实现
+type ScrollFilter interface {
+	Limit() int
+	MustSortColumn() string
+	SortColumn() (string, error)
+	SortDirection() string
+	Cursor() string
+}
+
+var cursorSecret = func() []byte {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return b
+}()
+
+// SetCursorSecret 游标签名的秘钥，默认随机生成，重启后已下发的游标失效
+// 多实例部署时需设置相同的秘钥，secret 为空时保留随机秘钥
+func SetCursorSecret(secret []byte) {
+	if len(secret) == 0 {
+		return
+	}
+	cursorSecret = secret
+}
+
+// cursor 上一页最后一条数据的排序键，依次为排序字段与主键
+type cursor struct {
+	Sort string        `json:"s"`
+	Keys []cursorValue `json:"k"`
+}
+
+type cursorValue struct {
+	Type  string `json:"t,omitempty"` // 非 json 原生类型，time 或 bytes
+	Value any    `json:"v"`
+}
+
+// Scroll 滚动翻页
+func (t Type[T]) Scroll(ctx context.Context, p ScrollFilter, opts ...QueryOption) ([]*T, string, error) {
+	return FindScroll[T](ctx, t.db, p, opts...)
+}
+
+// FindScroll 基于游标的滚动翻页，不统计总数，也不使用 OFFSET
+// 按 PagerFilter.Sort 与主键排序，Sort 为空时仅按主键排序，Sort 须在 SortSafelist 中
+// 返回当前页数据与下一页的游标，没有下一页时游标为空，接口响应可使用 web.FindScroll
+// 游标编码了上一页最后一条数据的排序键并签名，排序方式变更或游标被篡改时返回 ErrInvalidQuery
+// 排序字段不能为 NULL，opts 中的排序与分页条件会被忽略，例如 QuerySpec.Options 添加的排序
+func FindScroll[T any](ctx context.Context, db *gorm.DB, p ScrollFilter, opts ...QueryOption) ([]*T, string, error) {
+	items := make([]*T, 0)
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, "", err
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, "", fmt.Errorf("%s 没有主键", stmt.Schema.Name)
+	}
+	fields := []*schema.Field{pk}
+	if p.MustSortColumn() != "" {
+		col, err := p.SortColumn()
+		if err != nil {
+			return nil, "", invalidQuery("%s", err)
+		}
+		field := stmt.Schema.LookUpField(col)
+		if field == nil || field.DBName == "" {
+			return nil, "", invalidQuery("%s 不支持排序", col)
+		}
+		if field != pk {
+			fields = []*schema.Field{field, pk}
+		}
+	}
+	desc := p.SortDirection() == "DESC"
+	sort := sortKey(p, fields)
+
+	db = db.Model(new(T)).WithContext(ctx)
+	for _, opt := range opts {
+		db = opt(db)
+	}
+	// 游标依赖固定的排序，其它排序会导致翻页错乱
+	delete(db.Statement.Clauses, "ORDER BY")
+	delete(db.Statement.Clauses, "LIMIT")
+	if next := p.Cursor(); next != "" {
+		keys, err := decodeCursor(next, sort, len(fields))
+		if err != nil {
+			return nil, "", err
+		}
+		db = db.Where(keysetExpr(db, fields, keys, desc))
+	}
+	columns := make([]clause.OrderByColumn, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: f.DBName}, Desc: desc})
+	}
+	limit := p.Limit()
+	if err := db.Order(clause.OrderBy{Columns: columns}).Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, "", err
+	}
+	if len(items) <= limit {
+		return items, "", nil
+	}
+	items = items[:limit]
+
+	last := reflect.ValueOf(items[limit-1])
+	keys := make([]cursorValue, 0, len(fields))
+	for _, f := range fields {
+		v, _ := f.ValueOf(ctx, last)
+		cv, err := newCursorValue(v)
+		if err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, cv)
+	}
+	next, err := encodeCursor(cursor{Sort: sort, Keys: keys})
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
+
+// sortKey 游标只能用于相同的排序方式
+func sortKey(p ScrollFilter, fields []*schema.Field) string {
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, f.DBName)
+	}
+	return p.SortDirection() + " " + strings.Join(names, ",")
+}
+
+// keysetExpr 生成 (a, id) < (?, ?) 的等价条件，兼容不支持行比较的数据库
+// 例如 a < ? OR (a = ? AND id < ?)
+func keysetExpr(db *gorm.DB, fields []*schema.Field, keys []any, desc bool) clause.Expression {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	var sql strings.Builder
+	var args []any
+	for i, f := range fields {
+		if i > 0 {
+			sql.WriteString(" OR ")
+		}
+		sql.WriteString("(")
+		for j := 0; j < i; j++ {
+			fmt.Fprintf(&sql, "%s = ? AND ", db.Statement.Quote(fields[j].DBName))
+			args = append(args, keys[j])
+		}
+		fmt.Fprintf(&sql, "%s %s ?)", db.Statement.Quote(f.DBName), op)
+		args = append(args, keys[i])
+	}
+	return clause.Expr{SQL: "(" + sql.String() + ")", Vars: args}
+}
+
+func newCursorValue(v any) (cursorValue, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return cursorValue{}, err
+		}
+		v = dv
+	}
+	switch x := v.(type) {
+	case time.Time:
+		return cursorValue{Type: "time", Value: x.Format(time.RFC3339Nano)}, nil
+	case *time.Time:
+		if x != nil {
+			return cursorValue{Type: "time", Value: x.Format(time.RFC3339Nano)}, nil
+		}
+	case []byte:
+		return cursorValue{Type: "bytes", Value: base64.StdEncoding.EncodeToString(x)}, nil
+	}
+	return cursorValue{Value: v}, nil
+}
+
+func (c cursorValue) value() (any, error) {
+	switch c.Type {
+	case "time":
+		s, _ := c.Value.(string)
+		return time.Parse(time.RFC3339Nano, s)
+	case "bytes":
+		s, _ := c.Value.(string)
+		return base64.StdEncoding.DecodeString(s)
+	}
+	if n, ok := c.Value.(json.Number); ok {
+		if v, err := n.Int64(); err == nil {
+			return v, nil
+		}
+		return n.Float64()
+	}
+	return c.Value, nil
+}
+
+// encodeCursor base64(json).base64(hmac)
+func encodeCursor(c cursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + signCursor(payload), nil
+}
+
+func decodeCursor(s, sort string, n int) ([]any, error) {
+	errInvalid := invalidQuery("无效的游标")
+	payload, sign, ok := strings.Cut(s, ".")
+	if !ok || !hmac.Equal([]byte(sign), []byte(signCursor(payload))) {
+		return nil, errInvalid
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalid
+	}
+	var c cursor
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || len(c.Keys) != n {
+		return nil, errInvalid
+	}
+	if c.Sort != sort {
+		return nil, invalidQuery("排序方式已变更，请从第一页开始")
+	}
+	keys := make([]any, 0, n)
+	for _, k := range c.Keys {
+		v, err := k.value()
+		if err != nil {
+			return nil, errInvalid
+		}
+		keys = append(keys, v)
+	}
+	return keys, nil
+}
+
+func signCursor(payload string) string {
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,102 @@
+package orm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type scrollItem struct {
+	ID        int
+	Score     int
+	CreatedAt time.Time
+}
+
+func TestFindScroll(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(new(scrollItem)))
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 25; i++ {
+		// score 存在重复值，依靠主键区分
+		item := scrollItem{ID: i, Score: i % 4, CreatedAt: base.Add(time.Duration(i) * time.Millisecond)}
+		require.NoError(t, db.Create(&item).Error)
+	}
+	ctx := context.Background()
+
+	scroll := func(sort string) []int {
+		var ids []int
+		p := pagerFilter{Size: 10, Sort: sort, SortSafelist: []string{"score", "-score", "-created_at"}}
+		for range 10 {
+			items, next, err := FindScroll[scrollItem](ctx, db, p)
+			require.NoError(t, err)
+			for _, v := range items {
+				ids = append(ids, v.ID)
+			}
+			if next == "" {
+				return ids
+			}
+			require.Len(t, items, 10)
+			p.Next = next
+		}
+		t.Fatal("cursor does not end")
+		return nil
+	}
+
+	ids := scroll("")
+	require.Len(t, ids, 25)
+	require.Equal(t, 1, ids[0])
+	require.Equal(t, 25, ids[24])
+
+	ids = scroll("-created_at")
+	require.Len(t, ids, 25)
+	require.Equal(t, 25, ids[0])
+	require.Equal(t, 1, ids[24])
+
+	ids = scroll("-score")
+	require.Len(t, ids, 25)
+	require.Equal(t, []int{23, 19, 15, 11, 7, 3, 22, 18}, ids[:8])
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		require.False(t, seen[id])
+		seen[id] = true
+	}
+
+	// 过滤条件
+	items, next, err := FindScroll[scrollItem](ctx, db, pagerFilter{Size: 10}, Where("score = ?", 0))
+	require.NoError(t, err)
+	require.Len(t, items, 6)
+	require.Empty(t, next)
+
+	// opts 中的排序与分页被忽略
+	items, next, err = FindScroll[scrollItem](ctx, db, pagerFilter{Size: 10}, OrderBy("score DESC"), func(d *gorm.DB) *gorm.DB { return d.Limit(3) })
+	require.NoError(t, err)
+	require.Len(t, items, 10)
+	require.Equal(t, 1, items[0].ID)
+	require.NotEmpty(t, next)
+
+	// 不在白名单
+	_, _, err = FindScroll[scrollItem](ctx, db, pagerFilter{Sort: "id"})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+
+	p := pagerFilter{Size: 10, Sort: "score", SortSafelist: []string{"score", "-score"}}
+	_, next, err = FindScroll[scrollItem](ctx, db, p)
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+
+	// 篡改游标
+	p.Next = next[:len(next)-2] + "xx"
+	_, _, err = FindScroll[scrollItem](ctx, db, p)
+	require.ErrorIs(t, err, ErrInvalidQuery)
+
+	// 排序方式变更
+	p.Next, p.Sort = next, "-score"
+	_, _, err = FindScroll[scrollItem](ctx, db, p)
+	require.ErrorIs(t, err, ErrInvalidQuery)
+}
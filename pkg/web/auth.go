@@ -0,0 +1,225 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNoCredentials 请求未携带该认证方式的凭证，AuthChain 将尝试下一个认证方式
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator 身份认证
+// 认证成功返回 Claims，使各种认证方式得到相同的 Principal
+type Authenticator interface {
+	Authenticate(c *gin.Context) (*Claims, error)
+}
+
+// Challenger 认证失败时，响应 WWW-Authenticate 头
+type Challenger interface {
+	Challenge() string
+}
+
+// AuthenticatorFunc 函数适配 Authenticator
+type AuthenticatorFunc func(c *gin.Context) (*Claims, error)
+
+// Authenticate implements Authenticator.
+func (fn AuthenticatorFunc) Authenticate(c *gin.Context) (*Claims, error) {
+	return fn(c)
+}
+
+// AuthChain 按顺序尝试认证方式
+// 某个认证方式返回 ErrNoCredentials 或空的 Claims 时尝试下一个，返回其它错误则直接认证失败
+func AuthChain(auths ...Authenticator) gin.HandlerFunc {
+	var challenges []string
+	for _, a := range auths {
+		if v, ok := a.(Challenger); ok {
+			challenges = append(challenges, v.Challenge())
+		}
+	}
+	return func(c *gin.Context) {
+		for _, a := range auths {
+			claims, err := a.Authenticate(c)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err == nil && claims == nil {
+				continue
+			}
+			if err != nil {
+				abortUnauthorized(c, challenges, err)
+				return
+			}
+			SetClaims(c, claims)
+			c.Next()
+			return
+		}
+		abortUnauthorized(c, challenges, ErrNoCredentials)
+	}
+}
+
+// abortUnauthorized 失败原因仅记录日志，响应中的 details 只在调试模式下返回
+func abortUnauthorized(c *gin.Context, challenges []string, err error) {
+	if !errors.Is(err, ErrNoCredentials) {
+		L(c.Request.Context()).Warn("身份验证失败", "err", err)
+	}
+	for _, v := range challenges {
+		c.Writer.Header().Add("WWW-Authenticate", v)
+	}
+	AbortWithStatusJSON(c, ErrUnauthorizedToken.With(err.Error()))
+}
+
+// BearerAuthenticator Authorization: Bearer <jwt>
+type BearerAuthenticator struct {
+	keys  *KeySet
+	store RevokeStorer
+}
+
+// NewBearerAuthenticator store 为 nil 时不检查吊销
+func NewBearerAuthenticator(ks *KeySet, store RevokeStorer) *BearerAuthenticator {
+	return &BearerAuthenticator{keys: ks, store: store}
+}
+
+// Authenticate implements Authenticator.
+func (a *BearerAuthenticator) Authenticate(c *gin.Context) (*Claims, error) {
+	auth := c.Request.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return nil, ErrNoCredentials
+	}
+	claims, err := verifyAccessToken(c, a.keys, a.store, auth[len(prefix):])
+	if err != nil {
+		return nil, err
+	}
+	c.Set(token, auth)
+	return claims, nil
+}
+
+// APIKeyLookup 通过 api key 查找身份，不存在时返回 error
+type APIKeyLookup func(ctx context.Context, key string) (*Claims, error)
+
+// APIKeyAuthenticator 机器客户端使用的 api key，依次从请求头和 query 参数读取
+type APIKeyAuthenticator struct {
+	Header string // 默认 X-API-Key
+	Query  string // 为空时不从 query 读取
+	Lookup APIKeyLookup
+}
+
+// NewAPIKeyAuthenticator ...
+func NewAPIKeyAuthenticator(header, query string, lookup APIKeyLookup) *APIKeyAuthenticator {
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return &APIKeyAuthenticator{Header: header, Query: query, Lookup: lookup}
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeyAuthenticator) Authenticate(c *gin.Context) (*Claims, error) {
+	key := c.GetHeader(a.Header)
+	if key == "" && a.Query != "" {
+		key = c.Query(a.Query)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	return a.Lookup(c, key)
+}
+
+// StaticAPIKeys 固定的 api key 列表，适用于少量内部服务
+func StaticAPIKeys(keys map[string]*Claims) APIKeyLookup {
+	return func(_ context.Context, key string) (*Claims, error) {
+		for k, v := range keys {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+				return v, nil
+			}
+		}
+		return nil, errors.New("api key 无效")
+	}
+}
+
+// BasicVerify 校验用户名和密码，成功返回身份
+type BasicVerify func(ctx context.Context, username, password string) (*Claims, error)
+
+// BasicAuthenticator HTTP Basic 认证，适用于内部工具
+type BasicAuthenticator struct {
+	Realm  string
+	Verify BasicVerify
+}
+
+// NewBasicAuthenticator ...
+func NewBasicAuthenticator(realm string, verify BasicVerify) *BasicAuthenticator {
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	return &BasicAuthenticator{Realm: realm, Verify: verify}
+}
+
+// Authenticate implements Authenticator.
+func (a *BasicAuthenticator) Authenticate(c *gin.Context) (*Claims, error) {
+	user, passwd, ok := c.Request.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return a.Verify(c, user, passwd)
+}
+
+// Challenge implements Challenger.
+func (a *BasicAuthenticator) Challenge() string {
+	return `Basic realm="` + strings.ReplaceAll(a.Realm, `"`, `\"`) + `"`
+}
+
+// CookieAuthenticator 浏览器会话，cookie 中保存访问令牌
+// cookie 设置了 HttpOnly 与 SameSite=Lax，跨站请求不会携带
+type CookieAuthenticator struct {
+	Name  string // cookie 名称，默认 session
+	Path  string // 默认 /
+	keys  *KeySet
+	store RevokeStorer
+}
+
+// NewCookieAuthenticator store 为 nil 时不检查吊销
+func NewCookieAuthenticator(name string, ks *KeySet, store RevokeStorer) *CookieAuthenticator {
+	if name == "" {
+		name = "session"
+	}
+	return &CookieAuthenticator{Name: name, Path: "/", keys: ks, store: store}
+}
+
+// Authenticate implements Authenticator.
+func (a *CookieAuthenticator) Authenticate(c *gin.Context) (*Claims, error) {
+	v, err := c.Cookie(a.Name)
+	if err != nil || v == "" {
+		return nil, ErrNoCredentials
+	}
+	return verifyAccessToken(c, a.keys, a.store, v)
+}
+
+// SetCookie 登录成功后写入会话 cookie
+func (a *CookieAuthenticator) SetCookie(c *gin.Context, token string, maxAge time.Duration) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     a.Name,
+		Value:    token,
+		Path:     a.Path,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearCookie 注销时清除会话 cookie
+func (a *CookieAuthenticator) ClearCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     a.Name,
+		Value:    "",
+		Path:     a.Path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthChain(t *testing.T) {
+	ks := NewKeySetFromSecret("test_secret_key")
+	cookie := NewCookieAuthenticator("", ks, nil)
+
+	r := gin.New()
+	r.Use(AuthChain(
+		// 返回空的 Claims 视为未携带凭证
+		AuthenticatorFunc(func(*gin.Context) (*Claims, error) { return nil, nil }),
+		NewBearerAuthenticator(ks, nil),
+		NewAPIKeyAuthenticator("", "api_key", StaticAPIKeys(map[string]*Claims{
+			"machine-key": {UID: 100, Role: "machine"},
+		})),
+		NewBasicAuthenticator("internal", func(_ context.Context, username, password string) (*Claims, error) {
+			if username == "admin" && password == "123456" {
+				return &Claims{UID: 200, Username: username, Role: "admin"}, nil
+			}
+			return nil, errors.New("用户名或密码错误")
+		}),
+		cookie,
+	))
+	r.GET("/", func(c *gin.Context) {
+		p, ok := GetPrincipal(c)
+		require.True(t, ok)
+		c.String(http.StatusOK, p.Role)
+	})
+
+	token, err := ks.NewToken(TokenInput{UID: 1, Role: "user", Exires: time.Minute})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		fn     func(*http.Request)
+		code   int
+		expect string
+	}{
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }, 200, "user"},
+		{"bearer invalid", func(r *http.Request) { r.Header.Set("Authorization", "Bearer abc") }, 401, ""},
+		{"api key header", func(r *http.Request) { r.Header.Set("X-API-Key", "machine-key") }, 200, "machine"},
+		{"api key query", func(r *http.Request) { r.URL.RawQuery = "api_key=machine-key" }, 200, "machine"},
+		{"api key invalid", func(r *http.Request) { r.Header.Set("X-API-Key", "abc") }, 401, ""},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("admin", "123456") }, 200, "admin"},
+		{"basic invalid", func(r *http.Request) { r.SetBasicAuth("admin", "abc") }, 401, ""},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: cookie.Name, Value: token}) }, 200, "user"},
+		{"none", func(*http.Request) {}, 401, ""},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		tc.fn(req)
+		r.ServeHTTP(w, req)
+		require.Equal(t, tc.code, w.Code, tc.name)
+		if tc.code == 200 {
+			require.Equal(t, tc.expect, w.Body.String(), tc.name)
+		} else {
+			require.Contains(t, w.Header().Get("WWW-Authenticate"), `Basic realm="internal"`, tc.name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Principal 当前请求的身份，由鉴权中间件写入上下文
+type Principal struct {
+	UID         int
+	Username    string
+	GroupID     int
+	GroupLevel  int8
+	Role        string
+	Level       int      // 等级从 1 开始，等级越小，权限越大；0 表示未设置
+	Permissions []string // 权限字符串，如 user:read，支持 * 与 user:* 通配
+}
+
+// NewPrincipal 通过令牌创建身份
+func NewPrincipal(claims *Claims) *Principal {
+	return &Principal{
+		UID:         claims.UID,
+		Username:    claims.Username,
+		GroupID:     claims.GroupID,
+		GroupLevel:  claims.GroupLevel,
+		Role:        claims.Role,
+		Level:       claims.Level,
+		Permissions: claims.Permissions,
+	}
+}
+
+// GetPrincipal 获取当前请求的身份，未鉴权时返回 false
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok
+}
+
+// HasRole 拥有其中一个角色即返回 true
+func (p *Principal) HasRole(roles ...string) bool {
+	return slices.Contains(roles, p.Role)
+}
+
+// HasLevel 等级是否满足，max 为允许的最大等级
+func (p *Principal) HasLevel(max int) bool {
+	return p.Level > 0 && p.Level <= max
+}
+
+// HasPermission 是否拥有权限
+func (p *Principal) HasPermission(perm string) bool {
+	return matchPermission(p.Permissions, perm)
+}
+
+func matchPermission(owned []string, perm string) bool {
+	for _, v := range owned {
+		if v == "*" || v == perm {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(v, "*"); ok && strings.HasPrefix(perm, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy 访问策略，各条件同时满足才放行，零值条件不做限制
+type Policy struct {
+	Roles       []string // 满足其中一个角色
+	MaxLevel    int      // 等级从 1 开始，等级越小权限越大，用户等级需 <= MaxLevel
+	Permissions []string // 需要拥有全部权限
+}
+
+// Authorizer 授权，可配置角色对应的权限
+type Authorizer struct {
+	rolePermissions map[string][]string
+}
+
+// NewAuthorizer rolePermissions 为角色对应的权限，与令牌内的权限合并判断
+func NewAuthorizer(rolePermissions map[string][]string) *Authorizer {
+	return &Authorizer{rolePermissions: rolePermissions}
+}
+
+// Allow 判断身份是否满足策略
+func (a *Authorizer) Allow(p *Principal, policy Policy) bool {
+	if len(policy.Roles) > 0 && !p.HasRole(policy.Roles...) {
+		return false
+	}
+	if policy.MaxLevel > 0 && !p.HasLevel(policy.MaxLevel) {
+		return false
+	}
+	for _, perm := range policy.Permissions {
+		if p.HasPermission(perm) {
+			continue
+		}
+		if a != nil && matchPermission(a.rolePermissions[p.Role], perm) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Require 授权中间件，应在鉴权中间件之后使用
+func (a *Authorizer) Require(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := GetPrincipal(c)
+		if !ok {
+			AbortWithStatusJSON(c, ErrUnauthorizedToken.With("未鉴权"))
+			return
+		}
+		if !a.Allow(p, policy) {
+			AbortWithStatusJSON(c, ErrPermissionDenied)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Authorize 授权中间件，仅使用令牌内的权限
+func Authorize(policy Policy) gin.HandlerFunc {
+	var a *Authorizer
+	return a.Require(policy)
+}
+
+// RequireRoles 满足其中一个角色
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return Authorize(Policy{Roles: roles})
+}
+
+// RequirePermissions 需要拥有全部权限
+func RequirePermissions(perms ...string) gin.HandlerFunc {
+	return Authorize(Policy{Permissions: perms})
+}
+
+// AuthLevel 等级从1开始，等级越小，权限越大
+func AuthLevel(level int) gin.HandlerFunc {
+	return Authorize(Policy{MaxLevel: level})
+}
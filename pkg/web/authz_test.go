@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	const secret = "test_secret_key"
+	authz := NewAuthorizer(map[string][]string{
+		"editor": {"article:*"},
+	})
+
+	r := gin.New()
+	g := r.Group("", AuthMiddleware(secret))
+	g.GET("/level", AuthLevel(2), func(c *gin.Context) {
+		p, _ := GetPrincipal(c)
+		require.Equal(t, GetLevel(c), p.Level)
+		require.Equal(t, GetRole(c), p.Role)
+		require.Equal(t, GetGroupID(c), p.GroupID)
+		c.String(http.StatusOK, "OK")
+	})
+	g.GET("/role", RequireRoles("admin", "editor"), func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+	g.GET("/perm", authz.Require(Policy{Permissions: []string{"article:write"}}), func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+	r.GET("/anonymous", AuthLevel(2), func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+
+	request := func(path string, input TokenInput) int {
+		input.Secret = secret
+		token, err := NewToken(input)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cases := []struct {
+		path   string
+		input  TokenInput
+		expect int
+	}{
+		{"/level", TokenInput{Level: 1, GroupID: 3, Role: "admin"}, http.StatusOK},
+		{"/level", TokenInput{Level: 2}, http.StatusOK},
+		{"/level", TokenInput{Level: 3}, http.StatusForbidden},
+		{"/level", TokenInput{}, http.StatusForbidden},
+		{"/role", TokenInput{Role: "editor"}, http.StatusOK},
+		{"/role", TokenInput{Role: "guest"}, http.StatusForbidden},
+		{"/perm", TokenInput{Role: "editor"}, http.StatusOK},
+		{"/perm", TokenInput{Role: "guest", Permissions: []string{"article:write"}}, http.StatusOK},
+		{"/perm", TokenInput{Role: "guest", Permissions: []string{"article:read"}}, http.StatusForbidden},
+		{"/perm", TokenInput{Permissions: []string{"*"}}, http.StatusOK},
+		{"/anonymous", TokenInput{Level: 1}, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.expect, request(tc.path, tc.input), "%s %+v", tc.path, tc.input)
+	}
+}
@@ -0,0 +1,450 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError 字段错误
+type FieldError struct {
+	Field string `json:"field"`           // 字段路径，使用 json 标签，如 items[0].name
+	Rule  string `json:"rule,omitempty"`  // 违反的规则，如 required
+	Param string `json:"param,omitempty"` // 规则参数，如 min=3 中的 3
+	Msg   string `json:"msg"`
+}
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldTagName)
+	}
+}
+
+// fieldTagName 字段名依次使用 json、form、uri、header 标签
+func fieldTagName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri", "header"} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+var (
+	maxBodySize        int64 = 32 << 20
+	maxMultipartMemory int64 = 8 << 20
+)
+
+// SetMaxBodySize 请求体大小限制，包含上传的文件，默认 32MB，<=0 时不限制
+// 超过限制时响应 413
+func SetMaxBodySize(n int64) {
+	maxBodySize = n
+}
+
+// SetMaxMultipartMemory 上传文件时使用的内存，超出部分写入临时文件，默认 8MB
+func SetMaxMultipartMemory(n int64) {
+	maxMultipartMemory = n
+}
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// Bind 绑定请求参数到 ptr，并使用 binding 标签校验
+// 绑定顺序为 header < query < body < uri，后者覆盖前者
+// header、query、表单与 uri 仅绑定声明了对应标签的字段，避免客户端通过其它来源覆盖字段
+// json 不覆盖仅声明了 header 或 uri 标签的字段
+// header 标签可使用规范格式 X-Token 或小写 x-token
+// map[string]string 类型的字段绑定 name[key]=value 格式的查询参数，如 filter[status]=1
+// body 根据 Content-Type 绑定 json、urlencoded 表单或 multipart 表单，表单使用 form 标签
+// multipart 文件字段类型为 *multipart.FileHeader 或 []*multipart.FileHeader
+func Bind(c *gin.Context, ptr any) error {
+	header := make(map[string][]string, len(c.Request.Header)*2)
+	for k, v := range c.Request.Header {
+		header[k] = v
+		header[strings.ToLower(k)] = v
+	}
+	if err := bindTagged(ptr, header, "header"); err != nil {
+		return ErrBadRequest.With(err.Error())
+	}
+	query := c.Request.URL.Query()
+	if err := bindTagged(ptr, query, "form"); err != nil {
+		return ErrBadRequest.With(err.Error())
+	}
+	bindQueryMaps(reflect.ValueOf(ptr), query)
+	if err := bindBody(c, ptr); err != nil {
+		return err
+	}
+	if len(c.Params) > 0 {
+		params := make(map[string][]string, len(c.Params))
+		for _, v := range c.Params {
+			params[v.Key] = []string{v.Value}
+		}
+		if err := bindTagged(ptr, params, "uri"); err != nil {
+			return ErrBadRequest.With(err.Error())
+		}
+	}
+	if err := binding.Validator.ValidateStruct(ptr); err != nil {
+		return bindError(c, err)
+	}
+	return nil
+}
+
+// bindTagged 仅绑定声明了 tag 标签的字段，支持嵌入的结构体
+// gin 会按字段名匹配未声明标签的字段，此处将声明的字段复制到临时结构体中绑定后再写回
+func bindTagged(ptr any, values map[string][]string, tag string) error {
+	var fields []reflect.StructField
+	var targets []reflect.Value
+	collectTagged(reflect.ValueOf(ptr), values, tag, &fields, &targets)
+	if len(fields) == 0 {
+		return nil
+	}
+	tmp := reflect.New(reflect.StructOf(fields))
+	if err := binding.MapFormWithTag(tmp.Interface(), values, tag); err != nil {
+		return err
+	}
+	for i, v := range targets {
+		v.Set(tmp.Elem().Field(i))
+	}
+	return nil
+}
+
+// collectTagged 收集请求中存在或声明了默认值的字段
+func collectTagged(v reflect.Value, values map[string][]string, tag string, fields *[]reflect.StructField, targets *[]reflect.Value) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		value, ok := sf.Tag.Lookup(tag)
+		if !ok {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				collectTagged(fv.Addr(), values, tag, fields, targets)
+			}
+			continue
+		}
+		name, opts, _ := strings.Cut(value, ",")
+		if name == "" || name == "-" || !fv.CanSet() {
+			continue
+		}
+		if _, ok := values[name]; !ok && !strings.Contains(opts, "default=") {
+			continue
+		}
+		*fields = append(*fields, reflect.StructField{
+			Name: fmt.Sprintf("F%d", len(*fields)),
+			Type: sf.Type,
+			Tag:  sf.Tag,
+		})
+		*targets = append(*targets, fv)
+	}
+}
+
+func bindBody(c *gin.Context, ptr any) error {
+	r := c.Request
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	if maxBodySize > 0 {
+		r.Body = http.MaxBytesReader(c.Writer, r.Body, maxBodySize)
+	}
+
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm:
+		if err := r.ParseForm(); err != nil {
+			return bodyError(err)
+		}
+		if err := bindTagged(ptr, r.PostForm, "form"); err != nil {
+			return ErrBadRequest.With(err.Error())
+		}
+	case binding.MIMEMultipartPOSTForm:
+		if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+			return bodyError(err)
+		}
+		if err := bindTagged(ptr, r.MultipartForm.Value, "form"); err != nil {
+			return ErrBadRequest.With(err.Error())
+		}
+		bindFiles(reflect.ValueOf(ptr), r.MultipartForm.File)
+	default:
+		// 未指定 Content-Type 时按 json 处理，兼容 ContentLength 为 -1 的分块传输
+		// json 会按字段名匹配未声明 json 标签的字段，解码后还原仅来自 header 或 uri 的字段
+		var guarded []reflect.Value
+		collectGuarded(reflect.ValueOf(ptr), &guarded)
+		saved := make([]reflect.Value, len(guarded))
+		for i, v := range guarded {
+			saved[i] = reflect.New(v.Type()).Elem()
+			saved[i].Set(v)
+		}
+		if err := json.NewDecoder(r.Body).Decode(ptr); err != nil && !errors.Is(err, io.EOF) {
+			return bodyError(err)
+		}
+		for i, v := range guarded {
+			v.Set(saved[i])
+		}
+	}
+	return nil
+}
+
+// collectGuarded 收集声明了 header 或 uri 标签且未声明 json 标签的字段，支持嵌入的结构体
+func collectGuarded(v reflect.Value, out *[]reflect.Value) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			collectGuarded(fv.Addr(), out)
+			continue
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		if _, ok := sf.Tag.Lookup("json"); ok {
+			continue
+		}
+		_, header := sf.Tag.Lookup("header")
+		_, uri := sf.Tag.Lookup("uri")
+		if header || uri {
+			*out = append(*out, fv)
+		}
+	}
+}
+
+func bodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return ErrRequestTooLarge.Withf("限制 %d 字节", maxErr.Limit)
+	}
+	return ErrBadRequest.With(HanddleJSONErr(err).Error())
+}
+
+// bindFiles 通过 form 标签绑定上传的文件，支持嵌入的结构体
+func bindFiles(v reflect.Value, files map[string][]*multipart.FileHeader) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			bindFiles(fv.Addr(), files)
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fhs := files[name]
+		if len(fhs) == 0 {
+			continue
+		}
+		switch sf.Type {
+		case fileHeaderType:
+			fv.Set(reflect.ValueOf(fhs[0]))
+		case fileHeaderSliceType:
+			fv.Set(reflect.ValueOf(fhs))
+		}
+	}
+}
+
+var stringMapType = reflect.TypeOf(map[string]string(nil))
+
+// bindQueryMaps 通过 form 标签将 name[key]=value 格式的查询参数绑定到 map[string]string
+func bindQueryMaps(v reflect.Value, query map[string][]string) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			bindQueryMaps(fv.Addr(), query)
+			continue
+		}
+		if sf.Type != stringMapType || !fv.CanSet() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		for k, vs := range query {
+			key, ok := strings.CutPrefix(k, name+"[")
+			if !ok || !strings.HasSuffix(key, "]") || len(vs) == 0 {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.MakeMap(stringMapType))
+			}
+			fv.SetMapIndex(reflect.ValueOf(strings.TrimSuffix(key, "]")), reflect.ValueOf(vs[len(vs)-1]))
+		}
+	}
+}
+
+// bindError 将绑定错误转换为 ErrBadRequest，校验失败时包含字段错误
+func bindError(ctx context.Context, err error) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return ErrBadRequest.With(HanddleJSONErr(err).Error())
+	}
+	locale := GetLocale(ctx)
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, newFieldError(fe, locale))
+	}
+	return ErrBadRequest.WithFields(fields...)
+}
+
+func newFieldError(fe validator.FieldError, locale string) FieldError {
+	field := fe.Namespace()
+	// 去掉顶层结构体名
+	if _, after, ok := strings.Cut(field, "."); ok {
+		field = after
+	}
+	return FieldError{
+		Field: field,
+		Rule:  fe.Tag(),
+		Param: fe.Param(),
+		Msg:   ruleMessage(fe, locale),
+	}
+}
+
+// 规则提示，%s 为规则参数
+// 长度类规则对字符串、切片与 map 使用 length 提示，其它类型使用 value 提示
+var ruleMessages = map[string]map[string]string{
+	LocaleZH: {
+		"required":   "不能为空",
+		"email":      "邮箱格式错误",
+		"url":        "链接格式错误",
+		"uuid":       "UUID 格式错误",
+		"numeric":    "必须是数字",
+		"oneof":      "必须是 [%s] 其中之一",
+		"len.length": "长度必须为 %s",
+		"min.length": "长度不能小于 %s",
+		"max.length": "长度不能大于 %s",
+		"len.value":  "必须等于 %s",
+		"min.value":  "不能小于 %s",
+		"max.value":  "不能大于 %s",
+		"gte.value":  "不能小于 %s",
+		"lte.value":  "不能大于 %s",
+		"gt.value":   "必须大于 %s",
+		"lt.value":   "必须小于 %s",
+		"gte.length": "长度不能小于 %s",
+		"lte.length": "长度不能大于 %s",
+		"gt.length":  "长度必须大于 %s",
+		"lt.length":  "长度必须小于 %s",
+		"default":    "不符合规则 %s",
+	},
+	LocaleEN: {
+		"required":   "is required",
+		"email":      "must be a valid email",
+		"url":        "must be a valid URL",
+		"uuid":       "must be a valid UUID",
+		"numeric":    "must be numeric",
+		"oneof":      "must be one of [%s]",
+		"len.length": "length must be %s",
+		"min.length": "length must be at least %s",
+		"max.length": "length must be at most %s",
+		"len.value":  "must be %s",
+		"min.value":  "must be at least %s",
+		"max.value":  "must be at most %s",
+		"gte.value":  "must be at least %s",
+		"lte.value":  "must be at most %s",
+		"gt.value":   "must be greater than %s",
+		"lt.value":   "must be less than %s",
+		"gte.length": "length must be at least %s",
+		"lte.length": "length must be at most %s",
+		"gt.length":  "length must be greater than %s",
+		"lt.length":  "length must be less than %s",
+		"default":    "failed on rule %s",
+	},
+}
+
+// RegisterRuleMessages 注册或覆盖校验规则的提示，%s 为规则参数
+func RegisterRuleMessages(locale string, msgs map[string]string) {
+	locale = strings.ToLower(locale)
+	m, ok := ruleMessages[locale]
+	if !ok {
+		m = make(map[string]string, len(msgs))
+		ruleMessages[locale] = m
+	}
+	for k, v := range msgs {
+		m[k] = v
+	}
+}
+
+func ruleMessage(fe validator.FieldError, locale string) string {
+	msgs, ok := ruleMessages[locale]
+	if !ok {
+		msgs = ruleMessages[DefaultLocale]
+	}
+	tag := fe.Tag()
+	switch fe.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if v, ok := msgs[tag+".length"]; ok {
+			return formatRule(v, fe.Param())
+		}
+	default:
+		if v, ok := msgs[tag+".value"]; ok {
+			return formatRule(v, fe.Param())
+		}
+	}
+	if v, ok := msgs[tag]; ok {
+		return formatRule(v, fe.Param())
+	}
+	return fmt.Sprintf(msgs["default"], tag)
+}
+
+func formatRule(format, param string) string {
+	if !strings.Contains(format, "%s") {
+		return format
+	}
+	return fmt.Sprintf(format, param)
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBind(t *testing.T) {
+	type base struct {
+		Token string `header:"X-Token"`
+	}
+	type input struct {
+		base
+		ID    int    `uri:"id" json:"-"`
+		Name  string `form:"name" json:"name" binding:"required"`
+		Page  int    `form:"page,default=1" json:"page"`
+		Trace string `header:"x-trace-id" form:"trace" json:"trace"`
+		Role  string `json:"role"`
+	}
+
+	r := gin.New()
+	r.Any("/users/:id", WarpH(func(_ *gin.Context, in *input) (*input, error) { return in, nil }))
+	do := func(req *http.Request) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		return w.Code, out
+	}
+
+	// header < query < body < uri
+	req := httptest.NewRequest(http.MethodPost, "/users/7?name=q&trace=query&page=2", strings.NewReader(`{"name":"body"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Token", "t")
+	req.Header.Set("X-Trace-Id", "header")
+	code, out := do(req)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "body", out["name"])
+	require.Equal(t, "query", out["trace"])
+	require.EqualValues(t, 2, out["page"])
+	require.Equal(t, "t", out["Token"])
+
+	// 未声明 header/uri 标签的字段不会按字段名绑定
+	req = httptest.NewRequest(http.MethodPost, "/users/7", strings.NewReader(`{"name":"body"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Role", "admin")
+	req.Header.Set("Name", "header")
+	_, out = do(req)
+	require.Empty(t, out["role"])
+	require.Equal(t, "body", out["name"])
+
+	// 仅声明 header 标签的字段不会被查询参数或 json 覆盖
+	req = httptest.NewRequest(http.MethodPost, "/users/7?Token=forged&name=q", strings.NewReader(`{"token":"forged","Role":"admin"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Token", "real")
+	_, out = do(req)
+	require.Equal(t, "real", out["Token"])
+	require.Equal(t, "admin", out["role"])
+	req = httptest.NewRequest(http.MethodPost, "/users/7?Role=admin&Token=forged", strings.NewReader("name=form&Token=forged"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, out = do(req)
+	require.Empty(t, out["Token"])
+	require.Empty(t, out["role"])
+
+	r.GET("/roles/:Role", WarpH(func(_ *gin.Context, in *input) (*input, error) { return in, nil }))
+	_, out = do(httptest.NewRequest(http.MethodGet, "/roles/admin?name=q", nil))
+	require.Empty(t, out["role"])
+
+	// 分块传输
+	req = httptest.NewRequest(http.MethodPut, "/users/7", io.MultiReader(strings.NewReader(`{"name":"chunked"}`)))
+	req.ContentLength = -1
+	_, out = do(req)
+	require.Equal(t, "chunked", out["name"])
+	require.EqualValues(t, 1, out["page"])
+
+	// urlencoded
+	req = httptest.NewRequest(http.MethodPost, "/users/7", strings.NewReader("name=form&page=3"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, out = do(req)
+	require.Equal(t, "form", out["name"])
+	require.EqualValues(t, 3, out["page"])
+
+	// 统一校验
+	req = httptest.NewRequest(http.MethodDelete, "/users/7", nil)
+	code, out = do(req)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.NotEmpty(t, out["fields"])
+}
+
+func TestBindMultipart(t *testing.T) {
+	defer SetMaxBodySize(maxBodySize)
+
+	type input struct {
+		ID     int                     `uri:"id"`
+		Name   string                  `form:"name"`
+		Avatar *multipart.FileHeader   `form:"avatar" binding:"required"`
+		Files  []*multipart.FileHeader `form:"files"`
+	}
+	var got input
+	r := gin.New()
+	r.POST("/users/:id", WarpH(func(_ *gin.Context, in *input) (any, error) {
+		got = *in
+		return nil, nil
+	}))
+
+	newRequest := func(size int) *http.Request {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		_ = w.WriteField("name", "ixugo")
+		f, _ := w.CreateFormFile("avatar", "a.png")
+		_, _ = f.Write(bytes.Repeat([]byte("a"), size))
+		for _, name := range []string{"1.txt", "2.txt"} {
+			f, _ := w.CreateFormFile("files", name)
+			_, _ = f.Write([]byte(name))
+		}
+		_ = w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/users/3", &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newRequest(10))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, 3, got.ID)
+	require.Equal(t, "ixugo", got.Name)
+	require.Equal(t, "a.png", got.Avatar.Filename)
+	require.EqualValues(t, 10, got.Avatar.Size)
+	require.Len(t, got.Files, 2)
+
+	SetMaxBodySize(1024)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newRequest(2048))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestBindQueryMap(t *testing.T) {
+	type input struct {
+		PagerFilter
+		Name string `form:"name"`
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?name=a&sort=-created_at,name&filter[status]=in:1,2&filter[name]=like:foo", nil)
+	var in input
+	require.NoError(t, Bind(c, &in))
+	require.Equal(t, "a", in.Name)
+	require.Equal(t, map[string]string{"status": "in:1,2", "name": "like:foo"}, in.Filter)
+	require.Equal(t, []SortField{{Column: "created_at", Desc: true}, {Column: "name"}}, in.SortFields())
+}
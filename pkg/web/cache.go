@@ -1,29 +1,20 @@
 package web
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/sha1"
+	"crypto/sha1" // nolint
 	"encoding/hex"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-type EtagWriter struct {
-	gin.ResponseWriter
-	body bytes.Buffer
-}
-
-func (w *EtagWriter) Unwrap() http.ResponseWriter {
-	return w.ResponseWriter
-}
-
-func (w *EtagWriter) Write(b []byte) (int, error) {
-	return w.body.Write(b)
-}
-
 // WebCache 主要用于缓存静态资源
 // Cache-Control: max-age=3600    # 缓存1小时
 // Cache-Control: no-cache        # 每次都需要验证
@@ -40,25 +31,202 @@ func CacheControlMaxAge(millisecond int) gin.HandlerFunc {
 	}
 }
 
+// EtagConfig 零值字段使用默认值
+type EtagConfig struct {
+	MaxSize int      // 超过该大小的响应不计算 ETag，默认 1MB
+	Weak    bool     // 生成弱 ETag，如 W/"..."
+	Bypass  []string // 不缓冲的 Content-Type 前缀，默认为流式与文件类型
+}
+
+var defaultEtagBypass = []string{
+	"text/event-stream",
+	"application/x-ndjson",
+	"application/octet-stream",
+	"application/zip",
+	"multipart/",
+	"video/",
+	"audio/",
+}
+
+// EtagHandler 使用默认配置的 Etag
 func EtagHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		bw := EtagWriter{
-			ResponseWriter: ctx.Writer,
+	return Etag(EtagConfig{})
+}
+
+// Etag 条件请求，仅处理状态码为 200 的 GET/HEAD 响应
+// 缓冲响应体计算 ETag，请求头 If-None-Match 匹配，或未携带 If-None-Match 且 If-Modified-Since 不早于 Last-Modified 时响应 304
+// 以下情况直接输出不缓冲: Range 请求、调用 Flush/Hijack、Content-Type 属于 Bypass、附件下载、超过 MaxSize
+// handler 已设置 ETag 时使用该值
+func Etag(cfg EtagConfig) gin.HandlerFunc {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 1 << 20
+	}
+	if cfg.Bypass == nil {
+		cfg.Bypass = defaultEtagBypass
+	}
+	return func(c *gin.Context) {
+		if (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) || c.GetHeader("Range") != "" {
+			c.Next()
+			return
 		}
-		ctx.Writer = &bw
-		ctx.Next()
+		w := EtagWriter{ResponseWriter: c.Writer, cfg: &cfg}
+		c.Writer = &w
+		defer func() { c.Writer = w.ResponseWriter }()
+		c.Next()
 
-		hash := sha1.New()
-		buf := bw.body.Bytes()
-		hash.Write(buf)
-		etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
-		if match := ctx.GetHeader("If-None-Match"); match != "" && match == etag {
-			ctx.Writer.WriteHeader(http.StatusNotModified)
+		if w.passthrough {
+			return
+		}
+		if w.Status() != http.StatusOK {
+			w.pass()
 			return
 		}
-		ctx.Header("ETag", etag)
-		if _, err := bw.ResponseWriter.Write(buf); err != nil {
-			slog.Error("write err", "err", err)
+		h := w.Header()
+		etag := h.Get("ETag")
+		if etag == "" {
+			sum := sha1.Sum(w.body.Bytes()) // nolint
+			etag = `"` + hex.EncodeToString(sum[:]) + `"`
+			if cfg.Weak {
+				etag = "W/" + etag
+			}
+			h.Set("ETag", etag)
+		}
+		if notModified(c.Request, etag, h.Get("Last-Modified")) {
+			// 304 不携带响应体相关的头
+			for _, k := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding"} {
+				h.Del(k)
+			}
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+			return
 		}
+		h.Set("Content-Length", strconv.Itoa(w.body.Len()))
+		w.pass()
+	}
+}
+
+// EtagWriter 缓冲响应体，无法缓冲时改为直接输出
+type EtagWriter struct {
+	gin.ResponseWriter
+	cfg         *EtagConfig
+	body        bytes.Buffer
+	checked     bool
+	passthrough bool
+}
+
+func (w *EtagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *EtagWriter) Write(b []byte) (int, error) {
+	if !w.passthrough && !w.checked {
+		w.checked = true
+		if w.bypass() {
+			w.pass()
+		}
+	}
+	if !w.passthrough && w.body.Len()+len(b) > w.cfg.MaxSize {
+		w.pass()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+func (w *EtagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 立即发送响应头，之后不再缓冲
+func (w *EtagWriter) WriteHeaderNow() {
+	w.pass()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush 流式响应，之后不再缓冲
+func (w *EtagWriter) Flush() {
+	w.pass()
+	w.ResponseWriter.Flush()
+}
+
+// Hijack websocket 等接管连接
+func (w *EtagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.passthrough = true
+	return w.ResponseWriter.Hijack()
+}
+
+// Written 缓冲中的响应视为已写入
+func (w *EtagWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Size 包含缓冲中的响应体
+func (w *EtagWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *EtagWriter) bypass() bool {
+	if w.Status() != http.StatusOK {
+		return true
+	}
+	h := w.Header()
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n > w.cfg.MaxSize {
+		return true
+	}
+	if strings.HasPrefix(strings.ToLower(h.Get("Content-Disposition")), "attachment") {
+		return true
+	}
+	ct := strings.ToLower(h.Get("Content-Type"))
+	for _, v := range w.cfg.Bypass {
+		if strings.HasPrefix(ct, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// pass 输出已缓冲的内容，之后直接写入
+func (w *EtagWriter) pass() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	if w.body.Len() == 0 {
+		return
+	}
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		slog.Error("write err", "err", err)
+	}
+	w.body.Reset()
+}
+
+// notModified If-None-Match 使用弱比较，存在时忽略 If-Modified-Since
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		tag := strings.TrimPrefix(etag, "W/")
+		for _, v := range strings.Split(inm, ",") {
+			v = strings.TrimSpace(v)
+			if v == "*" || strings.TrimPrefix(v, "W/") == tag {
+				return true
+			}
+		}
+		return false
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
 	}
+	return !modified.Truncate(time.Second).After(since)
 }
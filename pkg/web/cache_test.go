@@ -0,0 +1,100 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtag(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := gin.New()
+	r.Use(Etag(EtagConfig{MaxSize: 64}))
+	r.GET("/json", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"a": 1}) })
+	r.HEAD("/json", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"a": 1}) })
+	r.GET("/created", func(c *gin.Context) { c.String(http.StatusCreated, "ok") })
+	r.GET("/missing", func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{"msg": "no"}) })
+	r.POST("/json", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"a": 1}) })
+	r.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("x", 100)) })
+	r.GET("/sse", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, "data: 1\n\n")
+	})
+	r.GET("/flush", func(c *gin.Context) {
+		c.String(http.StatusOK, "a")
+		c.Writer.Flush()
+		c.String(http.StatusOK, "b")
+	})
+	r.GET("/modified", func(c *gin.Context) {
+		c.Header("Last-Modified", modified.Format(http.TimeFormat))
+		c.String(http.StatusOK, "m")
+	})
+
+	do := func(method, path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/json")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.Regexp(t, `^"[0-9a-f]{40}"$`, etag)
+	require.Equal(t, "7", w.Header().Get("Content-Length"))
+	require.Equal(t, `{"a":1}`, w.Body.String())
+
+	for _, inm := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		w = do(http.MethodGet, "/json", "If-None-Match", inm)
+		require.Equal(t, http.StatusNotModified, w.Code, inm)
+		require.Empty(t, w.Body.String())
+		require.Empty(t, w.Header().Get("Content-Type"))
+		require.Empty(t, w.Header().Get("Content-Length"))
+		require.Equal(t, etag, w.Header().Get("ETag"))
+	}
+	w = do(http.MethodGet, "/json", "If-None-Match", `"other"`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusNotModified, do(http.MethodHead, "/json", "If-None-Match", etag).Code)
+
+	// 不处理的请求保持原样
+	for path, code := range map[string]int{"/created": http.StatusCreated, "/missing": http.StatusNotFound} {
+		w = do(http.MethodGet, path, "If-None-Match", "*")
+		require.Equal(t, code, w.Code, path)
+		require.Empty(t, w.Header().Get("ETag"), path)
+		require.NotEmpty(t, w.Body.String(), path)
+	}
+	w = do(http.MethodPost, "/json")
+	require.Empty(t, w.Header().Get("ETag"))
+	w = do(http.MethodGet, "/json", "Range", "bytes=0-1")
+	require.Empty(t, w.Header().Get("ETag"))
+	w = do(http.MethodGet, "/large")
+	require.Empty(t, w.Header().Get("ETag"))
+	require.Len(t, w.Body.String(), 100)
+	w = do(http.MethodGet, "/sse")
+	require.Empty(t, w.Header().Get("ETag"))
+	require.Equal(t, "data: 1\n\n", w.Body.String())
+	w = do(http.MethodGet, "/flush")
+	require.Empty(t, w.Header().Get("ETag"))
+	require.Equal(t, "ab", w.Body.String())
+
+	// If-Modified-Since
+	require.Equal(t, http.StatusNotModified, do(http.MethodGet, "/modified", "If-Modified-Since", modified.Format(http.TimeFormat)).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/modified", "If-Modified-Since", modified.Add(-time.Second).Format(http.TimeFormat)).Code)
+	// 同时携带时以 If-None-Match 为准
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/modified", "If-None-Match", `"other"`, "If-Modified-Since", modified.Format(http.TimeFormat)).Code)
+
+	weak := gin.New()
+	weak.Use(Etag(EtagConfig{Weak: true}))
+	weak.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "w") })
+	w = httptest.NewRecorder()
+	weak.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.True(t, strings.HasPrefix(w.Header().Get("ETag"), `W/"`))
+}
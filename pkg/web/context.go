@@ -2,25 +2,77 @@ package web
 
 import (
 	"context"
+	"log/slog"
 
 	"github.com/gin-gonic/gin"
 )
 
-const traceIDKey = "TRACE_ID_KEY"
+const (
+	traceIDKey = "TRACE_ID_KEY"
+	loggerKey  = "LOGGER_KEY"
+)
+
+// ctxKey 写入 request context 的 key，gin.Context 仅支持 string 类型的 key
+type ctxKey string
+
+const (
+	ctxTraceIDKey ctxKey = traceIDKey
+	ctxLoggerKey  ctxKey = loggerKey
+)
 
 func MustTraceID(ctx context.Context) string {
-	v := ctx.Value(traceIDKey)
-	return v.(string)
+	v, _ := TraceID(ctx)
+	return v
 }
 
+// TraceID 支持 *gin.Context 与 c.Request.Context()
 func TraceID(ctx context.Context) (string, bool) {
-	v := ctx.Value(traceIDKey)
-	if v == nil {
-		return "", false
+	if v, ok := ctx.Value(traceIDKey).(string); ok {
+		return v, true
 	}
-	return v.(string), true
+	v, ok := ctx.Value(ctxTraceIDKey).(string)
+	return v, ok
 }
 
+// WithTraceID 将 trace id 写入 context，用于定时任务等非请求场景
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxTraceIDKey, id)
+}
+
+// SetTraceID 同时写入 request context，使 orm 等仅接收 context.Context 的调用也能获取
 func SetTraceID(ctx *gin.Context, id string) {
 	ctx.Set(traceIDKey, id)
+	ctx.Request = ctx.Request.WithContext(WithTraceID(ctx.Request.Context(), id))
+}
+
+// L 获取请求日志，已携带 trace_id/method/route，鉴权后携带 uid
+// 上下文中没有日志时返回 slog.Default()
+func L(ctx context.Context) *slog.Logger {
+	if l, ok := LoggerFromContext(ctx); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// LoggerFromContext 获取请求日志
+func LoggerFromContext(ctx context.Context) (*slog.Logger, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	if l, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return l, true
+	}
+	l, ok := ctx.Value(ctxLoggerKey).(*slog.Logger)
+	return l, ok
+}
+
+// WithLogger 将日志写入 context
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxLoggerKey, l)
+}
+
+// SetLogger 设置请求日志，同时写入 request context
+func SetLogger(c *gin.Context, l *slog.Logger) {
+	c.Set(loggerKey, l)
+	c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), l))
 }
@@ -8,23 +8,28 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"runtime"
+	"slices"
 	"strings"
 	// kerr "github.com/go-kratos/kratos/v2/errors"
 )
 
 // 常用错误
 var (
-	ErrUnknown           = NewError("UnKnow", "未知错误")
+	ErrUnknown           = NewHTTPError(http.StatusInternalServerError, "UnKnow", "未知错误")
 	ErrBadRequest        = NewError("ErrBadRequest", "请求参数有误")
-	ErrDB                = NewError("ErrStore", "数据发生错误")
-	ErrServer            = NewError("ErrServer", "服务器发生错误")
-	ErrUnauthorizedToken = NewError("ErrUnauthorizedToken", "用户已过期或错误")
+	ErrDB                = NewHTTPError(http.StatusInternalServerError, "ErrStore", "数据发生错误")
+	ErrServer            = NewHTTPError(http.StatusInternalServerError, "ErrServer", "服务器发生错误")
+	ErrUnauthorizedToken = NewHTTPError(http.StatusUnauthorized, "ErrUnauthorizedToken", "用户已过期或错误")
 	ErrJSON              = NewError("ErrUnmarshal", "JSON 编解码出错")
-	ErrNotFound          = NewError("ErrNotFound", "资源未找到")
+	ErrNotFound          = NewHTTPError(http.StatusNotFound, "ErrNotFound", "资源未找到")
 	ErrUsedLogic         = NewError("ErrUsedLogic", "使用逻辑错误")
-	ErrLoginLimiter      = NewError("ErrLoginLimiter", "触发登录限制")
-	ErrPermissionDenied  = NewError("ErrPermissionDenied", "没有该资源的权限")
-	ErrTimeout           = NewError("ErrTimeout", "请求超时")
+	ErrLoginLimiter      = NewHTTPError(http.StatusTooManyRequests, "ErrLoginLimiter", "触发登录限制")
+	ErrPermissionDenied  = NewHTTPError(http.StatusForbidden, "ErrPermissionDenied", "没有该资源的权限")
+	ErrTimeout           = NewHTTPError(http.StatusGatewayTimeout, "ErrTimeout", "请求超时")
+	ErrTooManyRequests   = NewHTTPError(http.StatusTooManyRequests, "ErrTooManyRequests", "请求过于频繁，请稍后再试")
+	ErrConflict          = NewHTTPError(http.StatusConflict, "ErrConflict", "数据已存在")
+	ErrRequestTooLarge   = NewHTTPError(http.StatusRequestEntityTooLarge, "ErrRequestTooLarge", "请求体过大")
 	ErrDevice            = NewError("ErrDevice", "设备异常")
 	ErrDeviceOffline     = NewError("ErrDeviceOffline", "设备离线")
 
@@ -35,7 +40,7 @@ var (
 var (
 	ErrNameOrPasswd    = NewError("ErrNameOrPasswd", "用户名或密码错误")
 	ErrCaptchaWrong    = NewError("ErrCaptchaWrong", "验证码错误")
-	ErrAccountDisabled = NewError("ErrAccountDisabled", "登录限制")
+	ErrAccountDisabled = NewHTTPError(http.StatusForbidden, "ErrAccountDisabled", "登录限制")
 )
 
 var _ error = NewError("test_new_error", "")
@@ -45,6 +50,11 @@ type Error struct {
 	reason  string   // 错误原因
 	msg     string   // 错误信息，用户可读
 	details []string // 错误扩展，开发可读
+	code    int      // http status code
+	custom  bool     // 通过 Msg 修改了提示内容，不再翻译
+	cause   error    // 原始错误
+	stack   []uintptr
+	fields  []FieldError // 字段错误
 }
 
 func (e Error) Error() string {
@@ -53,14 +63,28 @@ func (e Error) Error() string {
 	for _, v := range e.details {
 		msg.WriteString(";" + v)
 	}
+	for _, v := range e.fields {
+		msg.WriteString(";" + v.Field + " " + v.Msg)
+	}
+	if e.cause != nil {
+		msg.WriteString(";" + e.cause.Error())
+	}
 	return msg.String()
 }
 
 // E 可反序列化的 err
 type E struct {
-	Reason  string   `json:"reason"`
-	Msg     string   `json:"msg"`
-	Details []string `json:"details"`
+	Reason  string       `json:"reason"`
+	Msg     string       `json:"msg"`
+	Details []string     `json:"details"`
+	TraceID string       `json:"trace_id,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// Err 转换为 *Error，可通过 errors.Is 与本地定义的同 reason 错误比较
+// 提示内容来自服务端，不再翻译
+func (e E) Err(code int) *Error {
+	return &Error{reason: e.Reason, msg: e.Msg, details: e.Details, code: code, custom: true, fields: e.Fields}
 }
 
 func (e E) String() string {
@@ -78,15 +102,16 @@ func Unmarshal(b []byte) (e E) {
 	return
 }
 
-var codes = make(map[string]string, 8)
-
-// NewError 创建自定义错误
+// NewError 创建自定义错误，http status code 为 400
 func NewError(reason, msg string) *Error {
-	if _, ok := codes[reason]; ok {
-		panic(fmt.Sprintf("错误码 %s 已经存在，请更换一个", reason))
-	}
-	codes[reason] = msg
-	return &Error{reason: reason, msg: msg}
+	return NewHTTPError(http.StatusBadRequest, reason, msg)
+}
+
+// NewHTTPError 创建自定义错误，并指定 http status code
+// msg 为默认语言的提示，其它语言通过 RegisterMessages 注册
+func NewHTTPError(code int, reason, msg string) *Error {
+	register(reason, msg, code)
+	return &Error{reason: reason, msg: msg, code: code}
 }
 
 // Reason ..
@@ -99,9 +124,71 @@ func (e *Error) Message() string {
 	return e.msg
 }
 
-// Details 错误
+// Details 错误，包含原始错误
 func (e *Error) Details() []string {
-	return e.details
+	if e.cause == nil {
+		return e.details
+	}
+	return append(e.details[:len(e.details):len(e.details)], e.cause.Error())
+}
+
+// WithFields 字段错误，响应中通过 fields 返回
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	newErr := *e
+	newErr.fields = append(slices.Clip(e.fields), fields...)
+	return &newErr
+}
+
+// Fields 字段错误
+func (e *Error) Fields() []FieldError {
+	return e.fields
+}
+
+// Wrap 包装原始错误，可通过 errors.Is/As 判断
+// 调试模式下记录调用栈
+func (e *Error) Wrap(err error) *Error {
+	newErr := e.wrap(err)
+	if defaultDebug {
+		var pcs [32]uintptr
+		n := runtime.Callers(2, pcs[:])
+		newErr.stack = pcs[:n]
+	}
+	return newErr
+}
+
+func (e *Error) wrap(err error) *Error {
+	newErr := *e
+	newErr.cause = err
+	newErr.stack = nil
+	return &newErr
+}
+
+// Unwrap 返回原始错误
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reason 相同即视为同一错误，使 errors.Is(ErrNotFound.With("x"), ErrNotFound) 成立
+func (e *Error) Is(target error) bool {
+	v, ok := target.(*Error)
+	return ok && v.reason == e.reason
+}
+
+// Stack Wrap 时的调用栈，非调试模式为空
+func (e *Error) Stack() string {
+	if len(e.stack) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
 }
 
 // Map ..
@@ -128,25 +215,34 @@ func (e *Error) Withf(format string, args ...any) *Error {
 	return &newErr
 }
 
-// Msg 提示内容
+// Msg 提示内容，修改后不再按语言翻译
 func (e *Error) Msg(s string) *Error {
 	newErr := *e
 	newErr.msg = s
+	newErr.custom = true
 	return &newErr
 }
 
-// HTTPCode http status code
-// 权限相关错误 401
-// 程序错误 500
-// 其它错误 400
+// HTTPCode http status code，创建错误时指定，默认 400
 func (e *Error) HTTPCode() int {
-	switch e.reason {
-	case "":
+	if e.reason == "" {
 		return http.StatusOK
-	case ErrUnauthorizedToken.reason:
-		return http.StatusUnauthorized
 	}
-	return http.StatusBadRequest
+	if e.code == 0 {
+		return http.StatusBadRequest
+	}
+	return e.code
+}
+
+// LocalizedMessage 按语言获取提示内容，没有对应翻译时返回默认提示
+func (e *Error) LocalizedMessage(locale string) string {
+	if e.custom || locale == "" {
+		return e.msg
+	}
+	if msg, ok := lookupMessage(e.reason, locale); ok {
+		return msg
+	}
+	return e.msg
 }
 
 func HanddleJSONErr(err error) error {
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ixugo/goweb/pkg/orm"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestErrorHTTPCode(t *testing.T) {
+	require.Equal(t, http.StatusBadRequest, ErrBadRequest.HTTPCode())
+	require.Equal(t, http.StatusNotFound, ErrNotFound.HTTPCode())
+	require.Equal(t, http.StatusInternalServerError, ErrServer.HTTPCode())
+	require.Equal(t, http.StatusInternalServerError, ErrDB.With("x").HTTPCode())
+	require.Equal(t, http.StatusGatewayTimeout, ErrTimeout.HTTPCode())
+	require.Equal(t, http.StatusForbidden, ErrPermissionDenied.Msg("x").HTTPCode())
+	require.Equal(t, http.StatusUnauthorized, ErrUnauthorizedToken.HTTPCode())
+}
+
+func TestMatchLocale(t *testing.T) {
+	supported := []string{LocaleZH, LocaleEN}
+	require.Equal(t, LocaleEN, MatchLocale("en-US,en;q=0.9,zh;q=0.8", supported))
+	require.Equal(t, LocaleZH, MatchLocale("fr;q=0.9,zh-CN;q=0.8,en;q=0.5", supported))
+	require.Equal(t, LocaleEN, MatchLocale("zh;q=0.1,en;q=0.5", supported))
+	require.Equal(t, DefaultLocale, MatchLocale("fr", supported))
+	require.Equal(t, DefaultLocale, MatchLocale("", supported))
+}
+
+func TestErrorLocale(t *testing.T) {
+	r := gin.New()
+	r.Use(Locale())
+	r.GET("/", func(c *gin.Context) {
+		Fail(c, ErrNotFound.With("id=1"))
+	})
+	r.GET("/custom", func(c *gin.Context) {
+		Fail(c, ErrNotFound.Msg("用户不存在"))
+	})
+	r.GET("/auth", AuthMiddleware("test_secret_key"), func(*gin.Context) {})
+
+	do := func(path, lang string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Language", lang)
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		return w.Code, out
+	}
+
+	code, out := do("/", "en-US,en;q=0.9")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, "Resource not found", out["msg"])
+	require.Equal(t, "ErrNotFound", out["reason"])
+
+	_, out = do("/", "zh-CN")
+	require.Equal(t, "资源未找到", out["msg"])
+
+	// 自定义的提示不翻译
+	_, out = do("/custom", "en")
+	require.Equal(t, "用户不存在", out["msg"])
+
+	// 中间件返回的 401 同样翻译
+	code, out = do("/auth", "en")
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Equal(t, "Session expired or invalid", out["msg"])
+}
+
+func TestErrorCatalog(t *testing.T) {
+	catalog := GetErrorCatalog()
+	require.Equal(t, DefaultLocale, catalog.DefaultLocale)
+	require.Contains(t, catalog.Locales, LocaleEN)
+	for i, v := range catalog.Errors {
+		if i > 0 {
+			require.Less(t, catalog.Errors[i-1].Reason, v.Reason)
+		}
+		if v.Reason == "ErrNotFound" {
+			require.Equal(t, http.StatusNotFound, v.Status)
+			require.Equal(t, "Resource not found", v.Messages[LocaleEN])
+			require.Equal(t, "资源未找到", v.Messages[LocaleZH])
+		}
+	}
+	require.Panics(t, func() { NewError("ErrNotFound", "") })
+}
+
+func TestErrorWrap(t *testing.T) {
+	cause := fmt.Errorf("query user: %w", gorm.ErrRecordNotFound)
+	err := ErrNotFound.With("id=1").Wrap(cause)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NotErrorIs(t, err, ErrServer)
+	require.Equal(t, []string{"id=1", cause.Error()}, err.Details())
+	require.Contains(t, err.Stack(), "TestErrorWrap")
+
+	var e *Error
+	require.ErrorAs(t, fmt.Errorf("outer: %w", err), &e)
+	require.Equal(t, "ErrNotFound", e.Reason())
+
+	// 不影响原错误
+	require.Empty(t, ErrNotFound.Details())
+}
+
+func TestFailTranslate(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   int
+		reason string
+	}{
+		{fmt.Errorf("find: %w", gorm.ErrRecordNotFound), http.StatusNotFound, "ErrNotFound"},
+		{gorm.ErrDuplicatedKey, http.StatusConflict, "ErrConflict"},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout, "ErrTimeout"},
+		{fmt.Errorf("scroll: %w", orm.ErrInvalidQuery), http.StatusBadRequest, "ErrBadRequest"},
+		{fmt.Errorf("wrap: %w", ErrPermissionDenied.Wrap(gorm.ErrRecordNotFound)), http.StatusForbidden, "ErrPermissionDenied"},
+	}
+	for _, v := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		Fail(c, v.err)
+		require.Equal(t, v.code, w.Code, v.err.Error())
+		var out map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		require.Equal(t, v.reason, out["reason"])
+	}
+
+	// 原因只出现一次
+	var e *Error
+	require.ErrorAs(t, translateError(fmt.Errorf("scroll: %w", orm.ErrInvalidQuery)), &e)
+	require.Equal(t, []string{"scroll: invalid query"}, e.Details())
+}
+
+func TestProblemFormat(t *testing.T) {
+	defer SetErrorFormat(ErrorFormatDefault)
+
+	r := gin.New()
+	r.GET("/users/:id", func(c *gin.Context) {
+		Fail(c, ErrNotFound.With("id=1"), func(m map[string]any) { m["id"] = 1 })
+	})
+	do := func(accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/users/1?a=b", nil)
+		req.Header.Set("Accept", accept)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	SetErrorFormat(ErrorFormatProblem)
+	w := do("")
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, ContentTypeProblem, w.Header().Get("Content-Type"))
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	require.Equal(t, "about:blank", out["type"])
+	require.Equal(t, "Not Found", out["title"])
+	require.EqualValues(t, 404, out["status"])
+	require.Equal(t, "资源未找到", out["detail"])
+	require.Equal(t, "/users/1?a=b", out["instance"])
+	require.Equal(t, "ErrNotFound", out["reason"])
+	require.EqualValues(t, 1, out["id"])
+	require.NotContains(t, out, "msg")
+
+	SetErrorFormat(ErrorFormatNegotiate)
+	require.Equal(t, ContentTypeProblem, do("application/problem+json, application/json;q=0.9").Header().Get("Content-Type"))
+	require.Contains(t, do("application/json").Header().Get("Content-Type"), "application/json")
+	require.Contains(t, do("application/problem+json;q=0").Header().Get("Content-Type"), "application/json")
+	require.Contains(t, do("application/problem+json;q=0.00").Header().Get("Content-Type"), "application/json")
+	require.Equal(t, ContentTypeProblem, do("application/problem+json;q=0.5").Header().Get("Content-Type"))
+}
+
+func TestBindFieldErrors(t *testing.T) {
+	type item struct {
+		Name string `json:"name" binding:"required"`
+	}
+	type input struct {
+		Username string `json:"username" binding:"required,min=3"`
+		Age      int    `json:"age" binding:"gte=18"`
+		Role     string `json:"role" binding:"oneof=admin user"`
+		Items    []item `json:"items" binding:"dive"`
+	}
+
+	r := gin.New()
+	r.Use(Locale())
+	r.POST("/", WarpH(func(_ *gin.Context, in *input) (any, error) { return in, nil }))
+	r.GET("/manual", func(c *gin.Context) {
+		v := NewValidator().
+			Check(false, "b", "b 错误").
+			Check(false, "a", "a 错误").
+			Check(false, "b", "重复")
+		Fail(c, v.Err())
+	})
+
+	do := func(method, path, body, lang string) (int, []FieldError) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Accept-Language", lang)
+		r.ServeHTTP(w, req)
+		var out struct {
+			Fields []FieldError `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		return w.Code, out.Fields
+	}
+
+	code, fields := do(http.MethodPost, "/", `{"username":"ab","age":10,"role":"x","items":[{"name":""}]}`, "en")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, []FieldError{
+		{Field: "username", Rule: "min", Param: "3", Msg: "length must be at least 3"},
+		{Field: "age", Rule: "gte", Param: "18", Msg: "must be at least 18"},
+		{Field: "role", Rule: "oneof", Param: "admin user", Msg: "must be one of [admin user]"},
+		{Field: "items[0].name", Rule: "required", Msg: "is required"},
+	}, fields)
+
+	_, fields = do(http.MethodPost, "/", `{"age":20,"role":"user"}`, "zh")
+	require.Equal(t, []FieldError{{Field: "username", Rule: "required", Msg: "不能为空"}}, fields)
+
+	_, fields = do(http.MethodGet, "/manual", "", "")
+	require.Equal(t, []FieldError{{Field: "b", Msg: "b 错误"}, {Field: "a", Msg: "a 错误"}}, fields)
+}
@@ -0,0 +1,504 @@
+package web
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportFormat 导出格式
+type ExportFormat string
+
+// 支持的导出格式
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+	ExportXLSX   ExportFormat = "xlsx"
+)
+
+// ParseExportFormat 为空时返回 csv
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(s)); f {
+	case "":
+		return ExportCSV, nil
+	case ExportCSV, ExportNDJSON, ExportXLSX:
+		return f, nil
+	}
+	return "", ErrBadRequest.Withf("不支持导出 %s 格式", s)
+}
+
+// ContentType 响应的 Content-Type
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	case ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ExportColumn 导出的列
+type ExportColumn struct {
+	Name  string // json 标签，用于选择列与 ndjson 的 key
+	Title string // export 标签，用于表头
+	index []int
+}
+
+// ExportColumns 通过 export 标签获取可导出的列，export:"标题"
+// 嵌入的结构体会展开，names 不为空时按 names 的顺序选择列
+func ExportColumns[T any](names ...string) ([]ExportColumn, error) {
+	all := exportColumns(reflect.TypeOf((*T)(nil)).Elem(), nil)
+	if len(names) == 0 {
+		return all, nil
+	}
+	out := make([]ExportColumn, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		i := -1
+		for j, v := range all {
+			if v.Name == name {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return nil, ErrBadRequest.Withf("%s 不支持导出", name)
+		}
+		out = append(out, all[i])
+	}
+	return out, nil
+}
+
+func exportColumns(t reflect.Type, index []int) []ExportColumn {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var out []ExportColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		idx := append(index[:len(index):len(index)], i)
+		title, ok := f.Tag.Lookup("export")
+		if f.Anonymous && !ok {
+			out = append(out, exportColumns(f.Type, idx)...)
+			continue
+		}
+		if !ok || title == "-" || !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = f.Name
+		}
+		out = append(out, ExportColumn{Name: name, Title: title, index: idx})
+	}
+	return out
+}
+
+// Exporter 流式写入 csv、ndjson、xlsx，不在内存中保留数据
+type Exporter[T any] struct {
+	format  ExportFormat
+	columns []ExportColumn
+	out     io.Writer
+	buf     *bufio.Writer
+
+	csv  *csv.Writer
+	zip  *zip.Writer
+	xlsx io.Writer
+
+	rows       int
+	total      int
+	onProgress func(Chunk)
+}
+
+// NewExporter columns 为空时导出全部可导出的列
+func NewExporter[T any](w io.Writer, format ExportFormat, columns []ExportColumn) (*Exporter[T], error) {
+	if len(columns) == 0 {
+		var err error
+		if columns, err = ExportColumns[T](); err != nil {
+			return nil, err
+		}
+	}
+	e := Exporter[T]{format: format, columns: columns, out: w, buf: bufio.NewWriterSize(w, 32<<10)}
+	switch format {
+	case ExportCSV:
+		// BOM 使 excel 以 utf-8 打开
+		_, _ = e.buf.WriteString("\uFEFF")
+		e.csv = csv.NewWriter(e.buf)
+		titles := make([]string, len(columns))
+		for i, v := range columns {
+			titles[i] = v.Title
+		}
+		if err := e.csv.Write(titles); err != nil {
+			return nil, err
+		}
+	case ExportXLSX:
+		if err := e.startXLSX(); err != nil {
+			return nil, err
+		}
+	case ExportNDJSON:
+	default:
+		return nil, ErrBadRequest.Withf("不支持导出 %s 格式", format)
+	}
+	return &e, nil
+}
+
+// SetTotal 总行数，用于进度
+func (e *Exporter[T]) SetTotal(total int) {
+	e.total = total
+}
+
+// OnProgress 每次 Write 后回调，可写入 SendChunk 使用的 channel
+func (e *Exporter[T]) OnProgress(fn func(Chunk)) {
+	e.onProgress = fn
+}
+
+// Rows 已写入的行数
+func (e *Exporter[T]) Rows() int {
+	return e.rows
+}
+
+// Write 写入一批数据并刷新到底层 writer
+func (e *Exporter[T]) Write(items ...*T) error {
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		if err := e.writeRow(reflect.ValueOf(item).Elem()); err != nil {
+			return err
+		}
+		e.rows++
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if e.onProgress != nil {
+		e.onProgress(Chunk{Total: max(e.total, e.rows), Current: e.rows, Success: e.rows})
+	}
+	return nil
+}
+
+func (e *Exporter[T]) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.zip != nil {
+		if err := e.zip.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := e.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := e.out.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Close 写入结尾并刷新，不会关闭底层 writer
+func (e *Exporter[T]) Close() error {
+	if e.zip != nil {
+		if _, err := io.WriteString(e.xlsx, `</sheetData></worksheet>`); err != nil {
+			return err
+		}
+		if err := e.zip.Close(); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+func (e *Exporter[T]) writeRow(v reflect.Value) error {
+	switch e.format {
+	case ExportCSV:
+		record := make([]string, len(e.columns))
+		for i, col := range e.columns {
+			fv, ok := fieldByIndex(v, col.index)
+			if ok {
+				record[i] = csvSafe(fv, formatCell(fv))
+			}
+		}
+		return e.csv.Write(record)
+	case ExportNDJSON:
+		_ = e.buf.WriteByte('{')
+		for i, col := range e.columns {
+			if i > 0 {
+				_ = e.buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(col.Name)
+			_, _ = e.buf.Write(key)
+			_ = e.buf.WriteByte(':')
+			fv, ok := fieldByIndex(v, col.index)
+			if !ok {
+				_, _ = e.buf.WriteString("null")
+				continue
+			}
+			b, err := json.Marshal(fv.Interface())
+			if err != nil {
+				return err
+			}
+			_, _ = e.buf.Write(b)
+		}
+		_, err := e.buf.WriteString("}\n")
+		return err
+	case ExportXLSX:
+		return e.writeXLSXRow(v)
+	}
+	return nil
+}
+
+// fieldByIndex 嵌入的指针为 nil 时返回 false
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			for v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return v, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// formatCell 时间使用 2006-01-02 15:04:05，其它复合类型使用 json
+func formatCell(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return formatTime(x)
+	case interface {
+		Format(string) string
+		IsZero() bool
+	}:
+		// 兼容嵌入 time.Time 的类型，如 orm.Time
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(time.DateTime)
+	case fmt.Stringer:
+		return x.String()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	b, _ := json.Marshal(v.Interface())
+	return string(b)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateTime)
+}
+
+// csvSafe 防止 excel 将字符串当作公式执行
+func csvSafe(v reflect.Value, s string) string {
+	if indirectKind(v) != reflect.String || s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
+
+func indirectKind(v reflect.Value) reflect.Kind {
+	t := v.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind()
+}
+
+// xlsx 仅包含一个工作表，单元格使用内联字符串，无需共享字符串表
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func (e *Exporter[T]) startXLSX() error {
+	e.zip = zip.NewWriter(e.buf)
+	for _, p := range xlsxParts {
+		w, err := e.zip.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, p.body); err != nil {
+			return err
+		}
+	}
+	w, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.xlsx = w
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData><row r="1">`)
+	for _, col := range e.columns {
+		b.WriteString(`<c t="inlineStr"><is><t>`)
+		xmlEscape(&b, col.Title)
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (e *Exporter[T]) writeXLSXRow(v reflect.Value) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, e.rows+2)
+	for _, col := range e.columns {
+		fv, ok := fieldByIndex(v, col.index)
+		if !ok {
+			b.WriteString(`<c/>`)
+			continue
+		}
+		s := formatCell(fv)
+		switch indirectKind(fv) {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if s != "" {
+				b.WriteString(`<c><v>` + s + `</v></c>`)
+				continue
+			}
+		}
+		b.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		xmlEscape(&b, s)
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(e.xlsx, b.String())
+	return err
+}
+
+// xmlEscape 转义并去掉 xml 不允许的控制字符
+func xmlEscape(b *strings.Builder, s string) {
+	for _, r := range s {
+		switch r {
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '&':
+			b.WriteString("&amp;")
+		case '"':
+			b.WriteString("&quot;")
+		case '\t', '\n', '\r':
+			b.WriteRune(r)
+		default:
+			if r < 0x20 || r == utf8.RuneError || r == 0xFFFE || r == 0xFFFF {
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+}
+
+// Export 流式导出到响应，查询参数 format 指定格式，columns 以逗号分隔选择列
+// fn 中分批调用 Exporter.Write，开始写入前出错时按 Fail 响应
+// 写入后出错时记录日志并 panic(http.ErrAbortHandler) 中断连接，recovery 中间件需放行
+func Export[T any](c *gin.Context, filename string, fn func(*Exporter[T]) error) {
+	format, err := ParseExportFormat(c.Query("format"))
+	if err != nil {
+		Fail(c, err)
+		return
+	}
+	var names []string
+	if v := c.Query("columns"); v != "" {
+		names = strings.Split(v, ",")
+	}
+	columns, err := ExportColumns[T](names...)
+	if err != nil {
+		Fail(c, err)
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", format.ContentType())
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Content-Type-Options", "nosniff")
+	filename += "." + string(format)
+	h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`,
+		asciiFilename(filename), url.PathEscape(filename)))
+
+	e, err := NewExporter[T](c.Writer, format, columns)
+	if err == nil {
+		err = fn(e)
+	}
+	if err == nil {
+		err = e.Close()
+	}
+	if err == nil {
+		return
+	}
+	if !c.Writer.Written() {
+		h.Del("Content-Disposition")
+		Fail(c, err)
+		return
+	}
+	L(c).Error("export", "err", err, "rows", e.Rows())
+	_ = c.Error(err)
+	// 已发送的数据不完整，中断连接让客户端感知失败
+	panic(http.ErrAbortHandler)
+}
+
+func asciiFilename(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
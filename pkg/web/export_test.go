@@ -0,0 +1,166 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type exportBase struct {
+	ID int `json:"id" export:"编号"`
+}
+
+type exportRow struct {
+	exportBase
+	Name      string     `json:"name" export:"名称"`
+	Score     float64    `json:"score" export:"分数"`
+	Tags      []string   `json:"tags" export:"标签"`
+	CreatedAt time.Time  `json:"created_at" export:"创建时间"`
+	DeletedAt *time.Time `json:"deleted_at" export:"删除时间"`
+	Secret    string     `json:"secret"`
+}
+
+func exportRows() []*exportRow {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	return []*exportRow{
+		{exportBase: exportBase{ID: 1}, Name: "a,\"b\"", Score: 1.5, Tags: []string{"x"}, CreatedAt: at},
+		{exportBase: exportBase{ID: 2}, Name: "=cmd()", Score: -2, CreatedAt: at, DeletedAt: &at, Secret: "s"},
+	}
+}
+
+func TestExportColumns(t *testing.T) {
+	cols, err := ExportColumns[exportRow]()
+	require.NoError(t, err)
+	names := make([]string, 0, len(cols))
+	for _, v := range cols {
+		names = append(names, v.Name)
+	}
+	require.Equal(t, []string{"id", "name", "score", "tags", "created_at", "deleted_at"}, names)
+
+	cols, err = ExportColumns[exportRow]("name", "id")
+	require.NoError(t, err)
+	require.Equal(t, "名称", cols[0].Title)
+	require.Equal(t, "编号", cols[1].Title)
+
+	_, err = ExportColumns[exportRow]("secret")
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NewExporter[exportRow](&buf, ExportCSV, nil)
+	require.NoError(t, err)
+	var chunks []Chunk
+	e.SetTotal(2)
+	e.OnProgress(func(c Chunk) { chunks = append(chunks, c) })
+	rows := exportRows()
+	require.NoError(t, e.Write(rows[0]))
+	require.NoError(t, e.Write(rows[1]))
+	require.NoError(t, e.Close())
+	require.Equal(t, []Chunk{{Total: 2, Current: 1, Success: 1}, {Total: 2, Current: 2, Success: 2}}, chunks)
+	require.Equal(t, "\uFEFF编号,名称,分数,标签,创建时间,删除时间\n"+
+		"1,\"a,\"\"b\"\"\",1.5,\"[\"\"x\"\"]\",2024-01-02 03:04:05,\n"+
+		"2,'=cmd(),-2,null,2024-01-02 03:04:05,2024-01-02 03:04:05\n", buf.String())
+
+	buf.Reset()
+	cols, err := ExportColumns[exportRow]("id", "name", "deleted_at")
+	require.NoError(t, err)
+	e, err = NewExporter[exportRow](&buf, ExportNDJSON, cols)
+	require.NoError(t, err)
+	require.NoError(t, e.Write(rows...))
+	require.NoError(t, e.Close())
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, `{"id":1,"name":"a,\"b\"","deleted_at":null}`, lines[0])
+
+	buf.Reset()
+	e, err = NewExporter[exportRow](&buf, ExportXLSX, cols)
+	require.NoError(t, err)
+	require.NoError(t, e.Write(rows...))
+	require.NoError(t, e.Close())
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var sheet []byte
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		// 每个部分都是合法的 xml
+		require.NoError(t, xml.Unmarshal(b, new(struct{})), f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			sheet = b
+		}
+	}
+	var ws struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				T string `xml:"t,attr"`
+				V string `xml:"v"`
+				S string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	require.NoError(t, xml.Unmarshal(sheet, &ws))
+	require.Len(t, ws.Rows, 3)
+	require.Equal(t, "名称", ws.Rows[0].Cells[1].S)
+	require.Equal(t, "1", ws.Rows[1].Cells[0].V)
+	require.Equal(t, `a,"b"`, ws.Rows[1].Cells[1].S)
+	require.Equal(t, "=cmd()", ws.Rows[2].Cells[1].S)
+}
+
+func TestExport(t *testing.T) {
+	r := gin.New()
+	r.GET("/export", func(c *gin.Context) {
+		Export(c, "用户", func(e *Exporter[exportRow]) error {
+			if c.Query("fail") != "" {
+				return ErrDB
+			}
+			return e.Write(exportRows()...)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?format=ndjson&columns=id,name", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename="__.ndjson"; filename*=UTF-8''%E7%94%A8%E6%88%B7.ndjson`, w.Header().Get("Content-Disposition"))
+	require.Equal(t, "{\"id\":1,\"name\":\"a,\\\"b\\\"\"}\n{\"id\":2,\"name\":\"=cmd()\"}\n", w.Body.String())
+
+	// 写入前出错按 Fail 响应
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?format=ndjson&fail=1", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Empty(t, w.Header().Get("Content-Disposition"))
+
+	for _, query := range []string{"format=pdf", "columns=secret"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?"+query, nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	// 写入后出错中断连接
+	r.GET("/broken", func(c *gin.Context) {
+		Export(c, "x", func(e *Exporter[exportRow]) error {
+			if err := e.Write(exportRows()...); err != nil {
+				return err
+			}
+			return errors.New("db closed")
+		})
+	})
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/broken", nil))
+	})
+}
@@ -0,0 +1,363 @@
+package web
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ixugo/goweb/pkg/queue"
+)
+
+// HubConfig 零值字段使用默认值
+type HubConfig struct {
+	Replay       uint8         // 每个主题保留的最近事件数，用于断线重连，默认 64
+	Buffer       int           // 每个订阅者的缓冲，写满视为慢消费者并断开，默认 64
+	Heartbeat    time.Duration // 心跳间隔，默认 15s
+	Retry        time.Duration // 告知客户端的重连间隔，默认 3s
+	WriteTimeout time.Duration // 单次写入超时，默认 10s
+	TopicTTL     time.Duration // 主题没有订阅者且空闲超过该时长后释放保留的事件，默认 5m
+}
+
+// EventReset Last-Event-ID 之后的事件已不在保留范围内，客户端需要重新拉取数据，Data 为主题
+const EventReset = "reset"
+
+// Hub 按主题分发事件，多个客户端可同时订阅，并保留最近的事件供 Last-Event-ID 续传
+/*
+	使用案例
+
+	hub := web.NewHub(web.HubConfig{})
+	router.GET("/events", func(c *gin.Context) {
+		hub.ServeSSE(c.Writer, c.Request, "orders")
+	})
+	hub.Publish("orders", web.Event{Event: "created", Data: b})
+*/
+type Hub struct {
+	cfg     HubConfig
+	mu      sync.Mutex
+	seq     uint64
+	evicted uint64 // 已释放的主题中最大的事件序号
+	closed  bool
+	logs    map[string]*topicLog
+	subs    map[*Subscription]struct{}
+	cancel  context.CancelFunc
+}
+
+// topicLog 主题保留的最近事件
+type topicLog struct {
+	events   *queue.CirQueue[Event]
+	last     uint64    // 最近一次发布的事件序号
+	dropped  uint64    // 已被覆盖的事件中最大的序号
+	activeAt time.Time // 最近一次发布或订阅者离开的时间
+}
+
+// NewHub ...
+func NewHub(cfg HubConfig) *Hub {
+	if cfg.Replay == 0 {
+		cfg.Replay = 64
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 64
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.Retry <= 0 {
+		cfg.Retry = 3 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 10 * time.Second
+	}
+	if cfg.TopicTTL <= 0 {
+		cfg.TopicTTL = 5 * time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := Hub{
+		cfg:    cfg,
+		logs:   make(map[string]*topicLog),
+		subs:   make(map[*Subscription]struct{}),
+		cancel: cancel,
+	}
+	go h.cleanup(ctx)
+	return &h
+}
+
+// cleanup 定期释放空闲的主题，避免按实体划分的主题(如 order:<id>)一直占用内存
+func (h *Hub) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.TopicTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.mu.Lock()
+			h.evictIdle(now)
+			h.mu.Unlock()
+		}
+	}
+}
+
+// evictIdle 释放没有订阅者且空闲超过 TopicTTL 的主题，调用方需持有锁
+func (h *Hub) evictIdle(now time.Time) {
+	active := make(map[string]struct{})
+	for s := range h.subs {
+		for _, topic := range s.topics {
+			active[topic] = struct{}{}
+		}
+	}
+	for topic, log := range h.logs {
+		if _, ok := active[topic]; ok || now.Sub(log.activeAt) < h.cfg.TopicTTL {
+			continue
+		}
+		h.evicted = max(h.evicted, log.last)
+		delete(h.logs, topic)
+	}
+}
+
+// Subscription 订阅，C 关闭表示订阅结束(主动取消、被判定为慢消费者或 Hub 关闭)
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	topics []string
+	hub    *Hub
+}
+
+// Close 取消订阅，可重复调用
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Publish 发布事件，ID 由 Hub 按递增序号生成，返回填充后的事件，Hub 关闭后忽略
+func (h *Hub) Publish(topic string, ev Event) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ev
+	}
+	h.seq++
+	ev.ID = strconv.FormatUint(h.seq, 10)
+	ev.Topic = topic
+
+	log, ok := h.logs[topic]
+	if !ok {
+		log = &topicLog{events: queue.NewCirQueue[Event](h.cfg.Replay)}
+		h.logs[topic] = log
+	}
+	if log.events.IsFull() {
+		log.dropped = eventSeq(log.events.Range()[0])
+	}
+	log.events.Push(ev)
+	log.last = h.seq
+	log.activeAt = time.Now()
+
+	for s := range h.subs {
+		if !slices.Contains(s.topics, topic) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			// 慢消费者直接断开，客户端重连后通过 Last-Event-ID 补齐
+			slog.Warn("hub evict slow subscriber", "topic", topic, "id", ev.ID)
+			h.remove(s)
+		}
+	}
+	return ev
+}
+
+// Subscribe 订阅主题，lastEventID 不为空时先补发其后保留的事件
+// 其后的事件已不在保留范围内时，补发 EventReset 事件
+func (h *Hub) Subscribe(lastEventID string, topics ...string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	replay := h.replay(lastEventID, topics)
+	ch := make(chan Event, h.cfg.Buffer+len(replay))
+	for _, ev := range replay {
+		ch <- ev
+	}
+	s := &Subscription{C: ch, ch: ch, topics: topics, hub: h}
+	if h.closed {
+		close(ch)
+		return s
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Join 追加订阅主题，lastEventID 不为空时补发该主题其后保留的事件
+func (s *Subscription) Join(topic, lastEventID string) {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; !ok || slices.Contains(s.topics, topic) {
+		return
+	}
+	s.topics = append(slices.Clip(s.topics), topic)
+	for _, ev := range h.replay(lastEventID, []string{topic}) {
+		select {
+		case s.ch <- ev:
+		default:
+			h.remove(s)
+			return
+		}
+	}
+}
+
+// Leave 取消订阅主题
+func (s *Subscription) Leave(topic string) {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.topics = slices.DeleteFunc(slices.Clone(s.topics), func(v string) bool { return v == topic })
+	h.touch(topic)
+}
+
+// touch 订阅者离开后，主题从此时开始计算空闲时长，调用方需持有锁
+func (h *Hub) touch(topics ...string) {
+	now := time.Now()
+	for _, topic := range topics {
+		if log, ok := h.logs[topic]; ok {
+			log.activeAt = now
+		}
+	}
+}
+
+// replay 返回 lastEventID 之后保留的事件，按序号排序，调用方需持有锁
+// 主题有事件已被覆盖或释放时，改为返回该主题的 EventReset 事件
+func (h *Hub) replay(lastEventID string, topics []string) []Event {
+	last, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil || last > h.seq {
+		return nil
+	}
+	var out []Event
+	for _, topic := range topics {
+		log, ok := h.logs[topic]
+		if (ok && last < log.dropped) || (!ok && last < h.evicted) {
+			out = append(out, Event{ID: strconv.FormatUint(h.seq, 10), Event: EventReset, Data: []byte(topic), Topic: topic})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, ev := range log.events.Range() {
+			if eventSeq(ev) > last {
+				out = append(out, ev)
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b Event) int {
+		return cmp.Compare(eventSeq(a), eventSeq(b))
+	})
+	return out
+}
+
+func eventSeq(ev Event) uint64 {
+	id, _ := strconv.ParseUint(ev.ID, 10, 64)
+	return id
+}
+
+// Len 当前订阅数
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Close 断开所有订阅，之后的 Publish 被忽略
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	h.cancel()
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+// remove 调用方需持有锁
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	close(s.ch)
+	h.touch(s.topics...)
+}
+
+// ServeSSE 以 text/event-stream 推送订阅的主题，连接断开或订阅结束时返回
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request, topics ...string) {
+	sub := h.Subscribe(r.Header.Get("Last-Event-ID"), topics...)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w) // nolint
+	write := func(s string) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(h.cfg.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := w.Write([]byte(s)); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if err := write(fmt.Sprintf("retry: %d\n\n", h.cfg.Retry.Milliseconds())); err != nil {
+		return
+	}
+
+	tick := time.NewTicker(h.cfg.Heartbeat)
+	defer tick.Stop()
+	ctx := r.Context()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			err = write(": ping\n\n")
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			err = write(formatEvent(ev))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// formatEvent 按 SSE 格式编码，多行数据拆分为多个 data 字段
+func formatEvent(ev Event) string {
+	var b strings.Builder
+	if ev.ID != "" {
+		b.WriteString("id: " + stripNewline(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		b.WriteString("event: " + stripNewline(ev.Event) + "\n")
+	}
+	data := strings.ReplaceAll(string(ev.Data), "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func stripNewline(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
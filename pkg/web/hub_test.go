@@ -0,0 +1,158 @@
+package web
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub(HubConfig{Replay: 3, Buffer: 2})
+	a := hub.Subscribe("", "a")
+	ab := hub.Subscribe("", "a", "b")
+	require.Equal(t, 2, hub.Len())
+
+	ev := hub.Publish("a", Event{Data: []byte("1")})
+	require.Equal(t, "1", ev.ID)
+	require.Equal(t, "a", ev.Topic)
+	hub.Publish("b", Event{Data: []byte("2")})
+
+	require.Equal(t, "1", (<-a.C).ID)
+	require.Equal(t, "1", (<-ab.C).ID)
+	require.Equal(t, "2", (<-ab.C).ID)
+
+	// 慢消费者被断开
+	hub.Publish("a", Event{Data: []byte("3")})
+	hub.Publish("a", Event{Data: []byte("4")})
+	hub.Publish("a", Event{Data: []byte("5")})
+	require.Zero(t, hub.Len())
+	var ids []string
+	for ev := range a.C {
+		ids = append(ids, ev.ID)
+	}
+	require.Equal(t, []string{"3", "4"}, ids)
+	ab.Close()
+	ab.Close()
+
+	// 按 Last-Event-ID 补发，跨主题按序号排序
+	hub.Publish("b", Event{Data: []byte("6")})
+	sub := hub.Subscribe("2", "b", "a")
+	ids = ids[:0]
+	for range 4 {
+		ids = append(ids, (<-sub.C).ID)
+	}
+	require.Equal(t, []string{"3", "4", "5", "6"}, ids)
+	// 未知的 ID 不补发
+	require.Empty(t, hub.Subscribe("99", "a").C)
+	require.Empty(t, hub.Subscribe("x", "a").C)
+
+	hub.Close()
+	_, ok := <-sub.C
+	require.False(t, ok)
+	hub.Publish("a", Event{Data: []byte("7")})
+	_, ok = <-hub.Subscribe("", "a").C
+	require.False(t, ok)
+}
+
+func TestHubReset(t *testing.T) {
+	hub := NewHub(HubConfig{Replay: 2, TopicTTL: time.Minute})
+	defer hub.Close()
+	for range 3 {
+		hub.Publish("a", Event{Data: []byte("x")})
+	}
+
+	// 2 仍在保留范围内
+	sub := hub.Subscribe("1", "a")
+	require.Equal(t, "2", (<-sub.C).ID)
+	require.Equal(t, "3", (<-sub.C).ID)
+	sub.Close()
+
+	// 1 已被覆盖，需要重新拉取
+	sub = hub.Subscribe("0", "a")
+	ev := <-sub.C
+	require.Equal(t, EventReset, ev.Event)
+	require.Equal(t, "3", ev.ID)
+	require.Equal(t, "a", string(ev.Data))
+	require.Empty(t, sub.C)
+
+	// 有订阅者时不释放
+	hub.Publish("b", Event{Data: []byte("y")})
+	hub.mu.Lock()
+	hub.evictIdle(time.Now().Add(2 * time.Minute))
+	require.Len(t, hub.logs, 1)
+	hub.mu.Unlock()
+
+	sub.Close()
+	hub.mu.Lock()
+	hub.evictIdle(time.Now().Add(2 * time.Minute))
+	require.Empty(t, hub.logs)
+	hub.mu.Unlock()
+
+	// 主题已释放
+	ev = <-hub.Subscribe("3", "b").C
+	require.Equal(t, EventReset, ev.Event)
+	require.Empty(t, hub.Subscribe("4", "b").C)
+}
+
+func TestHubServeSSE(t *testing.T) {
+	hub := NewHub(HubConfig{Heartbeat: 50 * time.Millisecond, Retry: time.Second})
+	hub.Publish("a", Event{Event: "x", Data: []byte("old")})
+	hub.Publish("a", Event{Event: "x", Data: []byte("line1\nline2")})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeSSE(w, r, "a")
+	}))
+	defer s.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	r := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var b strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return b.String()
+			}
+			b.WriteString(line)
+		}
+	}
+	require.Equal(t, "retry: 1000\n", readEvent())
+	require.Equal(t, "id: 2\nevent: x\ndata: line1\ndata: line2\n", readEvent())
+	require.Equal(t, ": ping\n", readEvent())
+
+	hub.Publish("a", Event{Data: []byte("new")})
+	for {
+		if ev := readEvent(); ev != ": ping\n" {
+			require.Equal(t, "id: 3\ndata: new\n", ev)
+			break
+		}
+	}
+
+	hub.Close()
+	_, err = r.ReadString('\n')
+	require.Error(t, err)
+}
+
+func TestSSEClose(t *testing.T) {
+	sse := NewSSE(4, time.Second)
+	sse.Publish(Event{ID: "1", Data: []byte("a")})
+	sse.Publish(Event{ID: "2", Data: []byte("b")})
+	sse.Close()
+	sse.Close()
+	sse.Publish(Event{ID: "3", Data: []byte("c")})
+
+	w := httptest.NewRecorder()
+	sse.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "id: 1\ndata: a\n\nid: 2\ndata: b\n\n", w.Body.String())
+}
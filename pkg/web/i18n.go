@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内置语言
+const (
+	LocaleZH = "zh"
+	LocaleEN = "en"
+)
+
+// DefaultLocale NewError 传入的提示所属的语言
+const DefaultLocale = LocaleZH
+
+const localeKey = "locale"
+
+type catalogEntry struct {
+	code     int
+	messages map[string]string // key 为语言
+}
+
+var (
+	catalogM sync.RWMutex
+	catalog  = make(map[string]*catalogEntry, 32)
+	locales  = []string{DefaultLocale}
+)
+
+func register(reason, msg string, code int) {
+	catalogM.Lock()
+	defer catalogM.Unlock()
+	if _, ok := catalog[reason]; ok {
+		panic(fmt.Sprintf("错误码 %s 已经存在，请更换一个", reason))
+	}
+	catalog[reason] = &catalogEntry{code: code, messages: map[string]string{DefaultLocale: msg}}
+}
+
+func lookupMessage(reason, locale string) (string, bool) {
+	catalogM.RLock()
+	defer catalogM.RUnlock()
+	v, ok := catalog[reason]
+	if !ok {
+		return "", false
+	}
+	msg, ok := v.messages[locale]
+	return msg, ok
+}
+
+// RegisterMessages 注册错误提示的翻译，key 为错误的 reason
+// 未通过 NewError 注册的 reason 会被忽略
+func RegisterMessages(locale string, msgs map[string]string) {
+	locale = strings.ToLower(locale)
+	catalogM.Lock()
+	defer catalogM.Unlock()
+	if !slices.Contains(locales, locale) {
+		locales = append(locales, locale)
+	}
+	for reason, msg := range msgs {
+		if v, ok := catalog[reason]; ok {
+			v.messages[locale] = msg
+		}
+	}
+}
+
+// Locales 已注册的语言
+func Locales() []string {
+	catalogM.RLock()
+	defer catalogM.RUnlock()
+	return slices.Clone(locales)
+}
+
+// Locale 根据 Accept-Language 选择语言，Fail 与 AbortWithStatusJSON 按此语言返回提示
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(localeKey, MatchLocale(c.GetHeader("Accept-Language"), Locales()))
+		c.Next()
+	}
+}
+
+// GetLocale 获取请求的语言，未使用 Locale 中间件时返回 DefaultLocale
+func GetLocale(ctx context.Context) string {
+	if v, ok := ctx.Value(localeKey).(string); ok {
+		return v
+	}
+	return DefaultLocale
+}
+
+// MatchLocale 按 Accept-Language 的权重匹配语言，例如 en-US,en;q=0.9,zh;q=0.8
+// 先完整匹配，再匹配主语言，都不匹配时返回 DefaultLocale
+func MatchLocale(acceptLanguage string, supported []string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" || name == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 {
+				continue
+			}
+			q = f
+		}
+		tags = append(tags, tag{name: strings.ToLower(strings.ReplaceAll(name, "_", "-")), q: q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if slices.Contains(supported, t.name) {
+			return t.name
+		}
+		base, _, _ := strings.Cut(t.name, "-")
+		if slices.Contains(supported, base) {
+			return base
+		}
+	}
+	return DefaultLocale
+}
+
+// localizedMessage 按请求的语言获取错误提示
+func localizedMessage(ctx context.Context, err Errorer) string {
+	if v, ok := err.(interface{ LocalizedMessage(string) string }); ok {
+		return v.LocalizedMessage(GetLocale(ctx))
+	}
+	return err.Message()
+}
+
+// CatalogError 错误目录中的一项
+type CatalogError struct {
+	Reason   string            `json:"reason"`
+	Status   int               `json:"status"`
+	Messages map[string]string `json:"messages"` // key 为语言
+}
+
+// ErrorCatalog 已注册的错误，按 reason 排序
+type ErrorCatalog struct {
+	DefaultLocale string         `json:"default_locale"`
+	Locales       []string       `json:"locales"`
+	Errors        []CatalogError `json:"errors"`
+}
+
+// GetErrorCatalog 获取错误目录，用于前端生成代码
+func GetErrorCatalog() ErrorCatalog {
+	catalogM.RLock()
+	defer catalogM.RUnlock()
+	out := ErrorCatalog{
+		DefaultLocale: DefaultLocale,
+		Locales:       slices.Clone(locales),
+		Errors:        make([]CatalogError, 0, len(catalog)),
+	}
+	for reason, v := range catalog {
+		msgs := make(map[string]string, len(v.messages))
+		for k, msg := range v.messages {
+			msgs[k] = msg
+		}
+		out.Errors = append(out.Errors, CatalogError{Reason: reason, Status: v.code, Messages: msgs})
+	}
+	slices.SortFunc(out.Errors, func(a, b CatalogError) int { return strings.Compare(a.Reason, b.Reason) })
+	return out
+}
+
+// ErrorCatalogHandler 输出错误目录
+func ErrorCatalogHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, GetErrorCatalog())
+	}
+}
+
+func init() {
+	RegisterMessages(LocaleEN, map[string]string{
+		"UnKnow":               "Unknown error",
+		"ErrBadRequest":        "Invalid request parameters",
+		"ErrStore":             "Data error",
+		"ErrServer":            "Internal server error",
+		"ErrUnauthorizedToken": "Session expired or invalid",
+		"ErrUnmarshal":         "JSON encoding error",
+		"ErrNotFound":          "Resource not found",
+		"ErrUsedLogic":         "Invalid operation",
+		"ErrLoginLimiter":      "Too many login attempts",
+		"ErrPermissionDenied":  "Permission denied",
+		"ErrTimeout":           "Request timed out",
+		"ErrTooManyRequests":   "Too many requests, please try again later",
+		"ErrConflict":          "Resource already exists",
+		"ErrRequestTooLarge":   "Request entity too large",
+		"ErrDevice":            "Device error",
+		"ErrDeviceOffline":     "Device offline",
+		"ErrAddNewDevice":      "Please add this device again",
+		"ErrNameOrPasswd":      "Incorrect username or password",
+		"ErrCaptchaWrong":      "Incorrect captcha",
+		"ErrAccountDisabled":   "Account is restricted",
+	})
+}
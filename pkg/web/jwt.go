@@ -1,60 +1,88 @@
 package web
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 // Claims ...
 type Claims struct {
-	UID        int
-	Username   string
-	GroupID    int
-	GroupLevel int8
-	Role       string
-	Level      int
+	UID         int
+	Username    string
+	GroupID     int
+	GroupLevel  int8
+	Role        string
+	Level       int
+	Permissions []string `json:",omitempty"`
+	TokenType   string   // 空值为访问令牌，refresh 为刷新令牌
 	jwt.RegisteredClaims
 }
 
 const (
-	uid        = "uid"
-	token      = "token"
-	username   = "username"
-	groupLevel = "group_level"
-	role       = "role"
+	uid          = "uid"
+	claimsKey    = "claims"
+	principalKey = "principal"
+	token        = "token"
+	username     = "username"
+	groupID      = "group_id"
+	groupLevel   = "group_level"
+	role         = "role"
+	level        = "level"
 )
 
-// AuthMiddleware 鉴权
+// AuthMiddleware 鉴权，使用单个 HS256 秘钥
 func AuthMiddleware(secret string) gin.HandlerFunc {
-	// var errResp = gin.H{
-	// "msg": "身份验证失败",
-	// }
-	return func(c *gin.Context) {
-		auth := c.Request.Header.Get("Authorization")
-		const prefix = "Bearer "
-		if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
-			AbortWithStatusJSON(c, ErrUnauthorizedToken.Msg("身份验证失败"))
-			return
-		}
-		claims, err := ParseToken(auth[len(prefix):], secret)
-		if err != nil {
-			AbortWithStatusJSON(c, ErrUnauthorizedToken.Msg("身份验证失败"))
-			return
-		}
-		if err := claims.Valid(); err != nil {
-			AbortWithStatusJSON(c, ErrUnauthorizedToken.Msg("身份验证失败"))
-			return
+	return AuthMiddlewareWithKeySet(NewKeySetFromSecret(secret))
+}
+
+// AuthMiddlewareWithKeySet 鉴权，根据 token 头部的 kid 选择验签秘钥
+func AuthMiddlewareWithKeySet(ks *KeySet) gin.HandlerFunc {
+	return authMiddleware(ks, nil)
+}
+
+func authMiddleware(ks *KeySet, store RevokeStorer) gin.HandlerFunc {
+	return AuthChain(NewBearerAuthenticator(ks, store))
+}
+
+// verifyAccessToken 校验访问令牌，store 为 nil 时不检查吊销
+func verifyAccessToken(ctx context.Context, ks *KeySet, store RevokeStorer, tokenString string) (*Claims, error) {
+	claims, err := ks.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.Valid(); err != nil {
+		return nil, err
+	}
+	if claims.TokenType == TokenTypeRefresh {
+		return nil, fmt.Errorf("刷新令牌不能用于访问")
+	}
+	if store != nil {
+		if err := checkRevoked(ctx, store, claims); err != nil {
+			return nil, err
 		}
+	}
+	return claims, nil
+}
 
-		c.Set(uid, claims.UID)
-		c.Set(username, claims.Username)
-		c.Set(token, auth)
-		c.Set(groupLevel, claims.GroupLevel)
-		c.Next()
+// SetClaims 将令牌内容写入上下文，供 GetUID/GetRole/GetPrincipal 等读取
+func SetClaims(c *gin.Context, claims *Claims) {
+	_, authed := c.Get(uid)
+	c.Set(claimsKey, claims)
+	c.Set(uid, claims.UID)
+	c.Set(username, claims.Username)
+	c.Set(groupID, claims.GroupID)
+	c.Set(groupLevel, claims.GroupLevel)
+	c.Set(role, claims.Role)
+	c.Set(level, claims.Level)
+	c.Set(principalKey, NewPrincipal(claims))
+	// 重复鉴权时日志已携带 uid
+	if l, ok := LoggerFromContext(c); ok && !authed {
+		SetLogger(c, l.With("uid", claims.UID))
 	}
 }
 
@@ -63,6 +91,16 @@ func GetUID(c *gin.Context) int {
 	return c.GetInt(uid)
 }
 
+// GetClaims 获取令牌内容
+func GetClaims(c *gin.Context) (*Claims, bool) {
+	v, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*Claims)
+	return claims, ok
+}
+
 // GetUsername 获取用户名
 func GetUsername(c *gin.Context) string {
 	return c.GetString(username)
@@ -73,6 +111,16 @@ func GetRole(c *gin.Context) string {
 	return c.GetString(role)
 }
 
+// GetLevel 获取用户等级
+func GetLevel(c *gin.Context) int {
+	return c.GetInt(level)
+}
+
+// GetGroupID 获取用户组 ID
+func GetGroupID(c *gin.Context) int {
+	return c.GetInt(groupID)
+}
+
 func GetGroupLevel(c *gin.Context) int8 {
 	v, exist := c.Get(groupLevel)
 	if exist {
@@ -81,25 +129,15 @@ func GetGroupLevel(c *gin.Context) int8 {
 	return 12
 }
 
-func AuthLevel(level int) gin.HandlerFunc {
-	// 等级从1开始，等级越小，权限越大
-	return func(c *gin.Context) {
-		l := c.GetInt("level")
-		if l > level || l == 0 {
-			Fail(c, ErrBadRequest.Msg("权限不足"))
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
-}
-
 // ParseToken 解析 token
 func ParseToken(tokenString string, secret string) (*Claims, error) {
+	return NewKeySetFromSecret(secret).ParseToken(tokenString)
+}
+
+// ParseToken 解析 token，根据 kid 选择验签秘钥
+func (ks *KeySet) ParseToken(tokenString string) (*Claims, error) {
 	var claims Claims
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &claims, ks.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
@@ -114,29 +152,43 @@ func ParseToken(tokenString string, secret string) (*Claims, error) {
 }
 
 type TokenInput struct {
-	UID        int
-	GroupID    int
-	GroupLevel int8
-	Username   string
-	Secret     string
-	Role       string
-	Level      int
-	Exires     time.Duration
+	UID         int
+	GroupID     int
+	GroupLevel  int8
+	Username    string
+	Secret      string // 使用 KeySet.NewToken 时忽略此参数
+	Role        string
+	Level       int
+	Permissions []string
+	Exires      time.Duration
+	TokenType   string
 }
 
-// NewToken 创建 token
+// NewToken 创建 token，使用 input.Secret 以 HS256 签名
 func NewToken(input TokenInput) (string, error) {
+	return NewKeySetFromSecret(input.Secret).NewToken(input)
+}
+
+// NewToken 创建 token，使用当前签名秘钥签名
+func (ks *KeySet) NewToken(input TokenInput) (string, error) {
+	return ks.Sign(newClaims(input))
+}
+
+func newClaims(input TokenInput) Claims {
 	if input.Exires <= 0 {
 		input.Exires = 2 * time.Hour
 	}
 	now := time.Now()
-	claims := Claims{
-		UID:        input.UID,
-		Username:   input.Username,
-		GroupID:    input.GroupID,
-		GroupLevel: input.GroupLevel,
-		Level:      input.Level,
+	return Claims{
+		UID:         input.UID,
+		Username:    input.Username,
+		GroupID:     input.GroupID,
+		GroupLevel:  input.GroupLevel,
+		Level:       input.Level,
+		Permissions: input.Permissions,
+		TokenType:   input.TokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),                          // jti，用于吊销
 			ExpiresAt: jwt.NewNumericDate(now.Add(input.Exires)), // 失效时间
 			IssuedAt:  jwt.NewNumericDate(now),                   // 签发时间
 			Issuer:    "xx@golang.space",                         // 签发人
@@ -144,6 +196,4 @@ func NewToken(input TokenInput) (string, error) {
 		},
 		Role: input.Role, // 角色
 	}
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return tokenClaims.SignedString([]byte(input.Secret))
 }
@@ -0,0 +1,308 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 支持的签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// JWTKey 签名秘钥
+// signKey 为 nil 时，该秘钥仅用于验签，常见于轮换后的旧秘钥
+type JWTKey struct {
+	ID        string // kid
+	Method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// CanSign 是否可以用于签名
+func (k *JWTKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// NewHMACKey 创建 HS256 秘钥
+func NewHMACKey(kid string, secret []byte) *JWTKey {
+	return &JWTKey{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// minHMACSecret HS256 秘钥最小长度，RFC 7518 要求不小于哈希输出长度
+const minHMACSecret = 32
+
+// NewJWTKey 通过算法与秘钥创建，signKey 可以为 nil
+// HS256: string/[]byte，长度不小于 32 字节
+// RS256: *rsa.PrivateKey/*rsa.PublicKey
+// ES256: *ecdsa.PrivateKey/*ecdsa.PublicKey，曲线为 P-256
+// EdDSA: ed25519.PrivateKey/ed25519.PublicKey
+func NewJWTKey(kid, alg string, signKey, verifyKey any) (*JWTKey, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("不支持的签名算法 %s", alg)
+	}
+	switch alg {
+	case AlgHS256:
+		if v, ok := signKey.(string); ok {
+			signKey = []byte(v)
+		}
+		if v, ok := verifyKey.(string); ok {
+			verifyKey = []byte(v)
+		}
+		if signKey == nil {
+			signKey = verifyKey
+		}
+		if verifyKey == nil {
+			verifyKey = signKey
+		}
+	case AlgRS256:
+		if k, ok := signKey.(*rsa.PrivateKey); ok && verifyKey == nil {
+			verifyKey = &k.PublicKey
+		}
+	case AlgES256:
+		if k, ok := signKey.(*ecdsa.PrivateKey); ok && verifyKey == nil {
+			verifyKey = &k.PublicKey
+		}
+	case AlgEdDSA:
+		if k, ok := signKey.(ed25519.PrivateKey); ok && verifyKey == nil {
+			verifyKey = k.Public()
+		}
+	default:
+		return nil, fmt.Errorf("不支持的签名算法 %s", alg)
+	}
+	if verifyKey == nil {
+		return nil, fmt.Errorf("秘钥 %s 缺少验签秘钥", kid)
+	}
+	if signKey != nil {
+		if err := checkKey(alg, signKey, true); err != nil {
+			return nil, fmt.Errorf("秘钥 %s: %w", kid, err)
+		}
+	}
+	if err := checkKey(alg, verifyKey, false); err != nil {
+		return nil, fmt.Errorf("秘钥 %s: %w", kid, err)
+	}
+	return &JWTKey{ID: kid, Method: method, signKey: signKey, verifyKey: verifyKey}, nil
+}
+
+// checkKey 校验秘钥类型与算法是否匹配，避免首次签名或验签时才发现配置错误
+func checkKey(alg string, key any, private bool) error {
+	ok := false
+	switch alg {
+	case AlgHS256:
+		b, _ := key.([]byte)
+		if len(b) < minHMACSecret {
+			return fmt.Errorf("HS256 秘钥长度不能小于 %d 字节", minHMACSecret)
+		}
+		return nil
+	case AlgRS256:
+		if private {
+			_, ok = key.(*rsa.PrivateKey)
+		} else {
+			_, ok = key.(*rsa.PublicKey)
+		}
+	case AlgES256:
+		var curve elliptic.Curve
+		if private {
+			if k, v := key.(*ecdsa.PrivateKey); v {
+				curve, ok = k.Curve, true
+			}
+		} else if k, v := key.(*ecdsa.PublicKey); v {
+			curve, ok = k.Curve, true
+		}
+		if ok && curve != elliptic.P256() {
+			return fmt.Errorf("ES256 需要 P-256 曲线")
+		}
+	case AlgEdDSA:
+		if private {
+			_, ok = key.(ed25519.PrivateKey)
+		} else {
+			_, ok = key.(ed25519.PublicKey)
+		}
+	}
+	if !ok {
+		return fmt.Errorf("秘钥类型 %T 与算法 %s 不匹配", key, alg)
+	}
+	return nil
+}
+
+// LoadPEMKey 从 PEM 文件加载非对称秘钥
+// privatePath 为空时，仅加载公钥用于验签
+func LoadPEMKey(kid, alg, privatePath, publicPath string) (*JWTKey, error) {
+	var signKey, verifyKey any
+	if privatePath != "" {
+		b, err := os.ReadFile(privatePath)
+		if err != nil {
+			return nil, err
+		}
+		if signKey, err = parsePrivateKey(alg, b); err != nil {
+			return nil, fmt.Errorf("解析私钥 %s 失败: %w", privatePath, err)
+		}
+	}
+	if publicPath != "" {
+		b, err := os.ReadFile(publicPath)
+		if err != nil {
+			return nil, err
+		}
+		if verifyKey, err = parsePublicKey(alg, b); err != nil {
+			return nil, fmt.Errorf("解析公钥 %s 失败: %w", publicPath, err)
+		}
+	}
+	return NewJWTKey(kid, alg, signKey, verifyKey)
+}
+
+func parsePrivateKey(alg string, b []byte) (any, error) {
+	switch alg {
+	case AlgRS256:
+		return jwt.ParseRSAPrivateKeyFromPEM(b)
+	case AlgES256:
+		return jwt.ParseECPrivateKeyFromPEM(b)
+	case AlgEdDSA:
+		return jwt.ParseEdPrivateKeyFromPEM(b)
+	}
+	return nil, fmt.Errorf("算法 %s 不支持 PEM 秘钥", alg)
+}
+
+func parsePublicKey(alg string, b []byte) (any, error) {
+	switch alg {
+	case AlgRS256:
+		return jwt.ParseRSAPublicKeyFromPEM(b)
+	case AlgES256:
+		return jwt.ParseECPublicKeyFromPEM(b)
+	case AlgEdDSA:
+		return jwt.ParseEdPublicKeyFromPEM(b)
+	}
+	return nil, fmt.Errorf("算法 %s 不支持 PEM 秘钥", alg)
+}
+
+// KeySet 秘钥集合
+// 包含一个签名秘钥和多个验签秘钥，通过 kid 头区分
+// 轮换时先添加新秘钥并设为签名秘钥，旧秘钥保留至已签发的 token 全部过期后再移除
+type KeySet struct {
+	m         sync.RWMutex
+	signer    string
+	hasSigner bool
+	keys      map[string]*JWTKey
+}
+
+// NewKeySet 创建秘钥集合，首个可签名的秘钥作为签名秘钥
+func NewKeySet(keys ...*JWTKey) (*KeySet, error) {
+	ks := KeySet{keys: make(map[string]*JWTKey, len(keys))}
+	for _, k := range keys {
+		if err := ks.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return &ks, nil
+}
+
+// NewKeySetFromSecret 兼容单秘钥 HS256 的用法，kid 为空
+func NewKeySetFromSecret(secret string) *KeySet {
+	ks, _ := NewKeySet(NewHMACKey("", []byte(secret)))
+	return ks
+}
+
+// Add 添加秘钥，若当前没有签名秘钥且该秘钥可签名，将其设为签名秘钥
+func (ks *KeySet) Add(key *JWTKey) error {
+	if key == nil {
+		return fmt.Errorf("秘钥不能为空")
+	}
+	ks.m.Lock()
+	defer ks.m.Unlock()
+	if _, ok := ks.keys[key.ID]; ok {
+		return fmt.Errorf("kid %s 已存在", key.ID)
+	}
+	ks.keys[key.ID] = key
+	if !ks.hasSigner && key.CanSign() {
+		ks.signer, ks.hasSigner = key.ID, true
+	}
+	return nil
+}
+
+// SetSigner 切换签名秘钥
+func (ks *KeySet) SetSigner(kid string) error {
+	ks.m.Lock()
+	defer ks.m.Unlock()
+	k, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("kid %s 不存在", kid)
+	}
+	if !k.CanSign() {
+		return fmt.Errorf("kid %s 仅可用于验签", kid)
+	}
+	ks.signer, ks.hasSigner = kid, true
+	return nil
+}
+
+// Remove 移除秘钥，不能移除签名秘钥
+func (ks *KeySet) Remove(kid string) error {
+	ks.m.Lock()
+	defer ks.m.Unlock()
+	if ks.hasSigner && kid == ks.signer {
+		return fmt.Errorf("kid %s 正在用于签名，不能移除", kid)
+	}
+	delete(ks.keys, kid)
+	return nil
+}
+
+// KIDs 全部秘钥 id
+func (ks *KeySet) KIDs() []string {
+	ks.m.RLock()
+	defer ks.m.RUnlock()
+	out := make([]string, 0, len(ks.keys))
+	for k := range ks.keys {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Signer 当前签名秘钥
+func (ks *KeySet) Signer() (*JWTKey, error) {
+	ks.m.RLock()
+	defer ks.m.RUnlock()
+	k, ok := ks.keys[ks.signer]
+	if !ks.hasSigner || !ok {
+		return nil, fmt.Errorf("未设置签名秘钥")
+	}
+	return k, nil
+}
+
+// Sign 使用签名秘钥签发，并在头部写入 kid
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	k, err := ks.Signer()
+	if err != nil {
+		return "", err
+	}
+	t := jwt.NewWithClaims(k.Method, claims)
+	if k.ID != "" {
+		t.Header["kid"] = k.ID
+	}
+	return t.SignedString(k.signKey)
+}
+
+// Keyfunc 根据 kid 查找验签秘钥，并校验算法一致，防止算法混淆攻击
+func (ks *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	ks.m.RLock()
+	k, ok := ks.keys[kid]
+	ks.m.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知的 kid %q", kid)
+	}
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("kid %q 签名算法不匹配", kid)
+	}
+	return k.verifyKey, nil
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenTypeRefresh 刷新令牌类型，不能用于访问接口
+const TokenTypeRefresh = "refresh"
+
+// TokenPair 访问令牌与刷新令牌
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresAt        int64  `json:"expires_at"`         // 访问令牌过期时间戳(秒)
+	RefreshExpiresAt int64  `json:"refresh_expires_at"` // 刷新令牌过期时间戳(秒)
+}
+
+// TokenManager 签发/刷新/吊销令牌
+type TokenManager struct {
+	keys           *KeySet
+	store          RevokeStorer
+	AccessExpires  time.Duration // 访问令牌有效期，默认 2 小时
+	RefreshExpires time.Duration // 刷新令牌有效期，默认 7 天
+}
+
+// NewTokenManager store 为吊销列表存储，可以使用 NewMemoryRevokeStore 或 NewDBRevokeStore
+func NewTokenManager(ks *KeySet, store RevokeStorer) *TokenManager {
+	return &TokenManager{
+		keys:           ks,
+		store:          store,
+		AccessExpires:  2 * time.Hour,
+		RefreshExpires: 7 * 24 * time.Hour,
+	}
+}
+
+// AuthMiddleware 鉴权，并检查令牌是否已吊销
+func (m *TokenManager) AuthMiddleware() gin.HandlerFunc {
+	return authMiddleware(m.keys, m.store)
+}
+
+// NewTokenPair 签发一对令牌
+func (m *TokenManager) NewTokenPair(input TokenInput) (*TokenPair, error) {
+	input.TokenType = ""
+	input.Exires = m.AccessExpires
+	access := newClaims(input)
+	accessToken, err := m.keys.Sign(access)
+	if err != nil {
+		return nil, err
+	}
+
+	input.TokenType = TokenTypeRefresh
+	input.Exires = m.RefreshExpires
+	refresh := newClaims(input)
+	refreshToken, err := m.keys.Sign(refresh)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresAt:        access.ExpiresAt.Unix(),
+		RefreshExpiresAt: refresh.ExpiresAt.Unix(),
+	}, nil
+}
+
+// Refresh 使用刷新令牌换取新的一对令牌
+// 旧的刷新令牌会被原子地吊销，重复或并发使用时仅有一次成功
+func (m *TokenManager) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := m.parseRefresh(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := m.store.RevokeOnce(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, ErrServer.With(err.Error())
+	}
+	if !ok {
+		return nil, ErrUnauthorizedToken.With("令牌已吊销")
+	}
+	return m.NewTokenPair(TokenInput{
+		UID:         claims.UID,
+		GroupID:     claims.GroupID,
+		GroupLevel:  claims.GroupLevel,
+		Username:    claims.Username,
+		Role:        claims.Role,
+		Level:       claims.Level,
+		Permissions: claims.Permissions,
+	})
+}
+
+// Logout 吊销当前访问令牌，refreshToken 不为空时一并吊销
+func (m *TokenManager) Logout(ctx context.Context, access *Claims, refreshToken string) error {
+	if access != nil {
+		if err := m.store.Revoke(ctx, access.ID, access.ExpiresAt.Time); err != nil {
+			return ErrServer.With(err.Error())
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	claims, err := m.parseRefresh(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	if access != nil && claims.UID != access.UID {
+		return ErrPermissionDenied.With("刷新令牌与当前用户不一致")
+	}
+	if err := m.store.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return ErrServer.With(err.Error())
+	}
+	return nil
+}
+
+// RevokeAll 吊销该用户此刻之前签发的全部令牌
+func (m *TokenManager) RevokeAll(ctx context.Context, uid int) error {
+	if err := m.store.RevokeUID(ctx, uid, time.Now(), m.RefreshExpires); err != nil {
+		return ErrServer.With(err.Error())
+	}
+	return nil
+}
+
+func (m *TokenManager) parseRefresh(ctx context.Context, refreshToken string) (*Claims, error) {
+	claims, err := m.keys.ParseToken(refreshToken)
+	if err != nil {
+		return nil, ErrUnauthorizedToken.With(err.Error())
+	}
+	if claims.TokenType != TokenTypeRefresh {
+		return nil, ErrUnauthorizedToken.With("不是刷新令牌")
+	}
+	if err := checkRevoked(ctx, m.store, claims); err != nil {
+		return nil, ErrUnauthorizedToken.With(err.Error())
+	}
+	return claims, nil
+}
+
+// checkRevoked 检查 jti 及用户级吊销
+func checkRevoked(ctx context.Context, store RevokeStorer, claims *Claims) error {
+	revoked, err := store.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return fmt.Errorf("令牌已吊销")
+	}
+	at, err := store.RevokedUIDAt(ctx, claims.UID)
+	if err != nil {
+		return err
+	}
+	// iat 精度为秒，与吊销同一秒内签发的令牌一并视为已吊销
+	if !at.IsZero() && claims.IssuedAt != nil && !claims.IssuedAt.After(at) {
+		return fmt.Errorf("令牌已吊销")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTokenManager(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	stores := map[string]RevokeStorer{
+		"memory": NewMemoryRevokeStore(),
+		"db":     NewDBRevokeStore(db).AutoMigrate(true),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testTokenManager(t, store)
+		})
+	}
+}
+
+func testTokenManager(t *testing.T, store RevokeStorer) {
+	ctx := context.Background()
+	m := NewTokenManager(NewKeySetFromSecret("test_secret_key"), store)
+
+	r := gin.New()
+	r.GET("/", m.AuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+	request := func(token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	pair, err := m.NewTokenPair(TokenInput{UID: 1, Username: "a"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, request(pair.AccessToken))
+	// 刷新令牌不能用于访问
+	require.Equal(t, http.StatusUnauthorized, request(pair.RefreshToken))
+
+	// 刷新后旧的刷新令牌失效
+	next, err := m.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	_, err = m.Refresh(ctx, pair.RefreshToken)
+	require.Error(t, err)
+	// 并发刷新仅有一次成功
+	pair, err = m.NewTokenPair(TokenInput{UID: 1, Username: "a"})
+	require.NoError(t, err)
+	var success atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Refresh(ctx, pair.RefreshToken); err == nil {
+				success.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 1, success.Load())
+	// 访问令牌不能用于刷新
+	_, err = m.Refresh(ctx, next.AccessToken)
+	require.Error(t, err)
+
+	// 注销
+	claims, err := m.keys.ParseToken(next.AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, m.Logout(ctx, claims, next.RefreshToken))
+	require.Equal(t, http.StatusUnauthorized, request(next.AccessToken))
+	_, err = m.Refresh(ctx, next.RefreshToken)
+	require.Error(t, err)
+
+	// 吊销用户全部会话
+	a, err := m.NewTokenPair(TokenInput{UID: 2})
+	require.NoError(t, err)
+	b, err := m.NewTokenPair(TokenInput{UID: 3})
+	require.NoError(t, err)
+	require.NoError(t, m.RevokeAll(ctx, 2))
+	require.Equal(t, http.StatusUnauthorized, request(a.AccessToken))
+	_, err = m.Refresh(ctx, a.RefreshToken)
+	require.Error(t, err)
+	require.Equal(t, http.StatusOK, request(b.AccessToken))
+
+	// 同一秒内签发的令牌也视为已吊销，下一秒重新登录的令牌有效
+	now := time.Now()
+	time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+	c, err := m.NewTokenPair(TokenInput{UID: 2})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, request(c.AccessToken))
+}
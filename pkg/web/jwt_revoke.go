@@ -0,0 +1,158 @@
+package web
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ixugo/goweb/pkg/conc"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokeStorer 令牌吊销列表
+// 记录保留至令牌过期即可，过期后的令牌本身已无法通过校验
+type RevokeStorer interface {
+	// Revoke 吊销指定 jti
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeOnce 原子地吊销 jti，已吊销时返回 false，用于刷新令牌的一次性消费
+	RevokeOnce(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	// IsRevoked jti 是否已吊销
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeUID 吊销该用户 at 之前签发的全部令牌，记录保留 ttl
+	RevokeUID(ctx context.Context, uid int, at time.Time, ttl time.Duration) error
+	// RevokedUIDAt 用户级吊销时间，未吊销返回零值
+	RevokedUIDAt(ctx context.Context, uid int) (time.Time, error)
+}
+
+var (
+	_ RevokeStorer = (*MemoryRevokeStore)(nil)
+	_ RevokeStorer = (*DBRevokeStore)(nil)
+)
+
+// MemoryRevokeStore 内存吊销列表，适用于单实例部署
+type MemoryRevokeStore struct {
+	jti *conc.TTLMap[string, struct{}]
+	uid *conc.TTLMap[int, time.Time]
+}
+
+// NewMemoryRevokeStore ...
+func NewMemoryRevokeStore() *MemoryRevokeStore {
+	return &MemoryRevokeStore{
+		jti: conc.NewTTLMap[string, struct{}](),
+		uid: conc.NewTTLMap[int, time.Time](),
+	}
+}
+
+// Revoke implements RevokeStorer.
+func (m *MemoryRevokeStore) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	m.jti.Store(jti, struct{}{}, time.Until(expiresAt))
+	return nil
+}
+
+// RevokeOnce implements RevokeStorer.
+func (m *MemoryRevokeStore) RevokeOnce(_ context.Context, jti string, expiresAt time.Time) (bool, error) {
+	_, loaded := m.jti.LoadOrStore(jti, struct{}{}, time.Until(expiresAt))
+	return !loaded, nil
+}
+
+// IsRevoked implements RevokeStorer.
+func (m *MemoryRevokeStore) IsRevoked(_ context.Context, jti string) (bool, error) {
+	_, ok := m.jti.Load(jti)
+	return ok, nil
+}
+
+// RevokeUID implements RevokeStorer.
+func (m *MemoryRevokeStore) RevokeUID(_ context.Context, uid int, at time.Time, ttl time.Duration) error {
+	m.uid.Store(uid, at, ttl)
+	return nil
+}
+
+// RevokedUIDAt implements RevokeStorer.
+func (m *MemoryRevokeStore) RevokedUIDAt(_ context.Context, uid int) (time.Time, error) {
+	at, _ := m.uid.Load(uid)
+	return at, nil
+}
+
+// TokenRevocation 吊销记录
+type TokenRevocation struct {
+	ID        string    `gorm:"primaryKey;comment:jti:<jti> 或 uid:<uid>"`
+	RevokedAt time.Time `gorm:"notNull;comment:吊销时间"`
+	ExpiresAt time.Time `gorm:"notNull;index;comment:过期时间"`
+}
+
+// TableName ...
+func (*TokenRevocation) TableName() string {
+	return "token_revocations"
+}
+
+// DBRevokeStore 数据库吊销列表，适用于多实例部署
+type DBRevokeStore struct {
+	db *gorm.DB
+}
+
+// NewDBRevokeStore ...
+func NewDBRevokeStore(db *gorm.DB) DBRevokeStore {
+	return DBRevokeStore{db: db}
+}
+
+// AutoMigrate ...
+func (d DBRevokeStore) AutoMigrate(ok bool) DBRevokeStore {
+	if !ok {
+		return d
+	}
+	if err := d.db.AutoMigrate(new(TokenRevocation)); err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Revoke implements RevokeStorer.
+func (d DBRevokeStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	return d.save(ctx, "jti:"+jti, time.Now(), expiresAt)
+}
+
+// RevokeOnce implements RevokeStorer.
+// 依赖主键冲突保证多实例下只有一次成功
+func (d DBRevokeStore) RevokeOnce(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	v := TokenRevocation{ID: "jti:" + jti, RevokedAt: time.Now(), ExpiresAt: expiresAt}
+	tx := d.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&v)
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// IsRevoked implements RevokeStorer.
+func (d DBRevokeStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	_, ok, err := d.get(ctx, "jti:"+jti)
+	return ok, err
+}
+
+// RevokeUID implements RevokeStorer.
+func (d DBRevokeStore) RevokeUID(ctx context.Context, uid int, at time.Time, ttl time.Duration) error {
+	return d.save(ctx, "uid:"+strconv.Itoa(uid), at, at.Add(ttl))
+}
+
+// RevokedUIDAt implements RevokeStorer.
+func (d DBRevokeStore) RevokedUIDAt(ctx context.Context, uid int) (time.Time, error) {
+	v, _, err := d.get(ctx, "uid:"+strconv.Itoa(uid))
+	return v.RevokedAt, err
+}
+
+// DeleteExpired 清理过期记录，可配合 conc.Timer 定时执行
+func (d DBRevokeStore) DeleteExpired(ctx context.Context) error {
+	return d.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(new(TokenRevocation)).Error
+}
+
+func (d DBRevokeStore) save(ctx context.Context, key string, at, expiresAt time.Time) error {
+	v := TokenRevocation{ID: key, RevokedAt: at, ExpiresAt: expiresAt}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at", "expires_at"}),
+	}).Create(&v).Error
+}
+
+func (d DBRevokeStore) get(ctx context.Context, key string) (TokenRevocation, bool, error) {
+	var v TokenRevocation
+	// 未吊销是常态，使用 Find 避免记录 record not found 日志
+	tx := d.db.WithContext(ctx).Where("id = ? AND expires_at > ?", key, time.Now()).Limit(1).Find(&v)
+	return v, tx.RowsAffected > 0, tx.Error
+}
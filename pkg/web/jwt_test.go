@@ -1,7 +1,20 @@
 package web
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestJWT(t *testing.T) {
@@ -19,3 +32,114 @@ func TestJWT(t *testing.T) {
 	// _, err = ParseToken(oldTokenStr, secret)
 	// require.NotNil(t, err)
 }
+
+func TestKeySetRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ks, err := NewKeySet(NewHMACKey("hs", []byte("test_secret_key")))
+	require.NoError(t, err)
+	old, err := ks.NewToken(TokenInput{UID: 1, Exires: time.Minute})
+	require.NoError(t, err)
+
+	for kid, v := range map[string][2]any{
+		AlgRS256: {rsaKey, nil},
+		AlgES256: {ecKey, nil},
+		AlgEdDSA: {edKey, nil},
+	} {
+		key, err := NewJWTKey(kid, kid, v[0], v[1])
+		require.NoError(t, err)
+		require.NoError(t, ks.Add(key))
+		require.NoError(t, ks.SetSigner(kid))
+
+		token, err := ks.NewToken(TokenInput{UID: 2, Exires: time.Minute})
+		require.NoError(t, err)
+		c, err := ks.ParseToken(token)
+		require.NoError(t, err)
+		require.EqualValues(t, 2, c.UID)
+	}
+
+	// 轮换后，旧秘钥签发的 token 依然有效
+	c, err := ks.ParseToken(old)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, c.UID)
+
+	// 移除旧秘钥后失效
+	require.NoError(t, ks.Remove("hs"))
+	_, err = ks.ParseToken(old)
+	require.Error(t, err)
+
+	// 不能移除签名秘钥
+	signer, err := ks.Signer()
+	require.NoError(t, err)
+	require.Error(t, ks.Remove(signer.ID))
+}
+
+func TestNewJWTKeyCheck(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = NewJWTKey("hs", AlgHS256, strings.Repeat("k", 32), nil)
+	require.NoError(t, err)
+	for name, v := range map[string][3]any{
+		"空秘钥":    {AlgHS256, "", nil},
+		"短秘钥":    {AlgHS256, "short", nil},
+		"类型不匹配":  {AlgES256, rsaKey, nil},
+		"公钥类型错误": {AlgRS256, nil, &p384.PublicKey},
+		"曲线错误":   {AlgES256, p384, nil},
+	} {
+		_, err := NewJWTKey(name, v[0].(string), v[1], v[2])
+		require.Error(t, err, name)
+	}
+}
+
+func TestKeySetLegacySecret(t *testing.T) {
+	const secret = "test_secret_key"
+	token, err := NewToken(TokenInput{UID: 1, Secret: secret})
+	require.NoError(t, err)
+	c, err := ParseToken(token, secret)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, c.UID)
+
+	_, err = ParseToken(token, "other")
+	require.Error(t, err)
+}
+
+func TestLoadPEMKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	priv := filepath.Join(t.TempDir(), "private.pem")
+	pub := filepath.Join(t.TempDir(), "public.pem")
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(priv, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0o600))
+	b, err = x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pub, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), 0o600))
+
+	signer, err := LoadPEMKey("v1", AlgRS256, priv, "")
+	require.NoError(t, err)
+	verifier, err := LoadPEMKey("v1", AlgRS256, "", pub)
+	require.NoError(t, err)
+	require.False(t, verifier.CanSign())
+
+	a, err := NewKeySet(signer)
+	require.NoError(t, err)
+	token, err := a.NewToken(TokenInput{UID: 3})
+	require.NoError(t, err)
+
+	// 仅持有公钥的服务可以验签，但不能签发
+	b2, err := NewKeySet(verifier)
+	require.NoError(t, err)
+	c, err := b2.ParseToken(token)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, c.UID)
+	_, err = b2.NewToken(TokenInput{UID: 3})
+	require.Error(t, err)
+}
@@ -1,6 +1,10 @@
 package web
 
 import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
 	"sync"
 	"time"
 
@@ -8,64 +12,239 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// RateLimiter 限流器
-func RateLimiter(r rate.Limit, b int) gin.HandlerFunc {
-	l := rate.NewLimiter(rate.Limit(r), b)
+// Rate 令牌桶限流参数
+type Rate struct {
+	Limit rate.Limit // 每秒产生的令牌数
+	Burst int        // 桶容量
+}
+
+// LimitResult 限流结果
+type LimitResult struct {
+	Allowed    bool
+	Limit      int           // 桶容量
+	Remaining  int           // 剩余令牌数
+	ResetAfter time.Duration // 令牌桶恢复满的时间
+	RetryAfter time.Duration // 被拒绝时，距离下一个令牌的时间
+}
+
+// newLimitResult 根据桶内剩余令牌计算结果
+func newLimitResult(allowed bool, tokens float64, limit Rate) LimitResult {
+	out := LimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: max(int(math.Floor(tokens)), 0),
+	}
+	if limit.Limit <= 0 || limit.Limit == rate.Inf {
+		return out
+	}
+	perToken := float64(time.Second) / float64(limit.Limit)
+	out.ResetAfter = time.Duration((float64(limit.Burst) - tokens) * perToken)
+	if !allowed {
+		out.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return out
+}
+
+// LimiterStorer 限流计数存储，多实例部署时使用共享存储
+type LimiterStorer interface {
+	// Take 从 key 对应的令牌桶中取出一个令牌
+	Take(ctx context.Context, key string, limit Rate) (LimitResult, error)
+}
+
+var (
+	_ LimiterStorer = (*MemoryLimiterStore)(nil)
+	_ LimiterStorer = (*DBLimiterStore)(nil)
+)
+
+// KeyFunc 提取限流 key，返回空串时不限流
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP 按连接的对端 IP 限流
+// 不读取 X-Forwarded-For，避免客户端伪造请求头绕过限流并无限制地创建令牌桶
+// 部署在反向代理之后时，所有请求共享代理的 IP，此时应通过 engine.SetTrustedProxies
+// 配置可信代理后使用 KeyByClientIP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.RemoteIP()
+}
+
+// KeyByClientIP 按 gin 解析的客户端 IP 限流
+// gin 默认信任全部代理，必须先通过 engine.SetTrustedProxies 限定可信代理
+func KeyByClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUID 按用户限流，应在鉴权中间件之后使用，未鉴权时不限流
+func KeyByUID(c *gin.Context) string {
+	p, ok := GetPrincipal(c)
+	if !ok {
+		return ""
+	}
+	return "uid:" + strconv.Itoa(p.UID)
+}
+
+// KeyByRoute 按路由限流，所有请求共享一个令牌桶
+func KeyByRoute(c *gin.Context) string {
+	return "route:" + c.Request.Method + " " + c.FullPath()
+}
+
+// KeyJoin 组合多个 key，例如每个 IP 在每个路由上单独限流
+func KeyJoin(fns ...KeyFunc) KeyFunc {
+	return func(c *gin.Context) string {
+		var key string
+		for i, fn := range fns {
+			v := fn(c)
+			if v == "" {
+				return ""
+			}
+			if i > 0 {
+				key += "|"
+			}
+			key += v
+		}
+		return key
+	}
+}
+
+// RateLimit 使用指定存储限流，存储出错时放行
+func RateLimit(store LimiterStorer, limit Rate, keyFn KeyFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !l.Allow() {
-			c.AbortWithStatusJSON(400, gin.H{"msg": "服务器繁忙"})
+		key := keyFn(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		result, err := store.Take(c, key, limit)
+		if err != nil {
+			slog.Error("rate limit", "key", key, "err", err)
+			c.Next()
+			return
+		}
+		setRateLimitHeader(c, result)
+		if !result.Allowed {
+			AbortWithStatusJSON(c, ErrTooManyRequests)
 			return
 		}
 		c.Next()
 	}
 }
 
-type client struct {
-	limiter    *rate.Limiter
-	lastSeenAt time.Time
+// setRateLimitHeader 参考 IETF draft-ietf-httpapi-ratelimit-headers
+// 被拒绝时额外响应 Retry-After，单位秒，向上取整
+func setRateLimitHeader(c *gin.Context, result LimitResult) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	}
 }
 
-// IPRateLimiter IP 限流器
-func IPRateLimiterForGin(r rate.Limit, b int) gin.HandlerFunc {
-	limiter := IPRateLimiter(r, b)
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RateLimiter 限流器，所有请求共享一个令牌桶
+func RateLimiter(r rate.Limit, b int) gin.HandlerFunc {
+	l := rate.NewLimiter(rate.Limit(r), b)
+	limit := Rate{Limit: r, Burst: b}
 	return func(c *gin.Context) {
-		ip := c.RemoteIP()
-		if !limiter(ip) {
-			c.AbortWithStatusJSON(400, gin.H{"msg": "服务器繁忙"})
+		now := time.Now()
+		allowed := l.AllowN(now, 1)
+		result := newLimitResult(allowed, l.TokensAt(now), limit)
+		setRateLimitHeader(c, result)
+		if !allowed {
+			AbortWithStatusJSON(c, ErrTooManyRequests)
 			return
 		}
 		c.Next()
 	}
 }
 
+// IPRateLimiterForGin IP 限流器
+func IPRateLimiterForGin(r rate.Limit, b int) gin.HandlerFunc {
+	return RateLimit(NewMemoryLimiterStore(30*time.Second, 3*time.Minute), Rate{Limit: r, Burst: b}, KeyByIP)
+}
+
 // IPRateLimiter IP 限流器
+// 清理协程随进程存在，需要停止时使用 NewMemoryLimiterStore
 func IPRateLimiter(r rate.Limit, b int) func(ip string) bool {
-	var m sync.Mutex
-	clients := make(map[string]*client)
-	// 定时清理
-	go func() {
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-		for {
-			<-ticker.C
-			m.Lock()
-			for k, v := range clients {
-				if time.Since(v.lastSeenAt) > 3*time.Minute {
-					delete(clients, k)
+	store := NewMemoryLimiterStore(30*time.Second, 3*time.Minute)
+	limit := Rate{Limit: r, Burst: b}
+	return func(ip string) bool {
+		result, _ := store.Take(context.Background(), ip, limit)
+		return result.Allowed
+	}
+}
+
+type client struct {
+	limiter    *rate.Limiter
+	lastSeenAt time.Time
+}
+
+// MemoryLimiterStore 进程内限流存储
+type MemoryLimiterStore struct {
+	m       sync.Mutex
+	clients map[string]*client
+	cancel  context.CancelFunc
+}
+
+// NewMemoryLimiterStore 每隔 interval 清理超过 idle 未访问的令牌桶
+func NewMemoryLimiterStore(interval, idle time.Duration) *MemoryLimiterStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := MemoryLimiterStore{
+		clients: make(map[string]*client),
+		cancel:  cancel,
+	}
+	go s.cleanup(ctx, interval, idle)
+	return &s
+}
+
+func (s *MemoryLimiterStore) cleanup(ctx context.Context, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.m.Lock()
+			for k, v := range s.clients {
+				if time.Since(v.lastSeenAt) > idle {
+					delete(s.clients, k)
 				}
 			}
-			m.Unlock()
-		}
-	}()
-	return func(ip string) bool {
-		m.Lock()
-		v, exist := clients[ip]
-		if !exist {
-			v = &client{limiter: rate.NewLimiter(r, b), lastSeenAt: time.Now()}
-			clients[ip] = v
+			s.m.Unlock()
 		}
-		v.lastSeenAt = time.Now()
-		m.Unlock()
-		return v.limiter.Allow()
 	}
 }
+
+// Take implements LimiterStorer.
+func (s *MemoryLimiterStore) Take(_ context.Context, key string, limit Rate) (LimitResult, error) {
+	now := time.Now()
+	s.m.Lock()
+	v, exist := s.clients[key]
+	if !exist {
+		v = &client{limiter: rate.NewLimiter(limit.Limit, limit.Burst)}
+		s.clients[key] = v
+	} else if v.limiter.Limit() != limit.Limit || v.limiter.Burst() != limit.Burst {
+		v.limiter.SetLimitAt(now, limit.Limit)
+		v.limiter.SetBurstAt(now, limit.Burst)
+	}
+	v.lastSeenAt = now
+	s.m.Unlock()
+
+	allowed := v.limiter.AllowN(now, 1)
+	return newLimitResult(allowed, v.limiter.TokensAt(now), limit), nil
+}
+
+// Len 令牌桶数量
+func (s *MemoryLimiterStore) Len() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.clients)
+}
+
+// Stop 停止后台清理
+func (s *MemoryLimiterStore) Stop() {
+	s.cancel()
+}
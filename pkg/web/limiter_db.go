@@ -0,0 +1,98 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitBucket 令牌桶
+type RateLimitBucket struct {
+	ID        string    `gorm:"primaryKey;comment:限流 key"`
+	Tokens    float64   `gorm:"notNull;comment:剩余令牌"`
+	UpdatedAt time.Time `gorm:"notNull;index;comment:更新时间"`
+}
+
+// TableName ...
+func (*RateLimitBucket) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// DBLimiterStore 数据库限流存储，多实例共享计数
+// 每次请求会开启一个事务并锁定对应行，适用于登录、导出等低频接口
+type DBLimiterStore struct {
+	db     *gorm.DB
+	cancel context.CancelFunc
+}
+
+// NewDBLimiterStore 每隔 interval 清理超过 idle 未访问的令牌桶
+func NewDBLimiterStore(db *gorm.DB, interval, idle time.Duration) *DBLimiterStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := DBLimiterStore{db: db, cancel: cancel}
+	go s.cleanup(ctx, interval, idle)
+	return &s
+}
+
+// AutoMigrate ...
+func (s *DBLimiterStore) AutoMigrate(ok bool) *DBLimiterStore {
+	if !ok {
+		return s
+	}
+	if err := s.db.AutoMigrate(new(RateLimitBucket)); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *DBLimiterStore) cleanup(ctx context.Context, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.db.WithContext(ctx).Where("updated_at < ?", time.Now().Add(-idle)).Delete(new(RateLimitBucket)).Error
+			if err != nil {
+				slog.Error("rate limit cleanup", "err", err)
+			}
+		}
+	}
+}
+
+// Take implements LimiterStorer.
+func (s *DBLimiterStore) Take(ctx context.Context, key string, limit Rate) (LimitResult, error) {
+	var result LimitResult
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		init := RateLimitBucket{ID: key, Tokens: float64(limit.Burst), UpdatedAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&init).Error; err != nil {
+			return err
+		}
+		var b RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", key).First(&b).Error; err != nil {
+			return err
+		}
+
+		tokens := b.Tokens
+		if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+			tokens += elapsed.Seconds() * float64(limit.Limit)
+		}
+		tokens = min(tokens, float64(limit.Burst))
+		allowed := tokens >= 1
+		if allowed {
+			tokens--
+		}
+		result = newLimitResult(allowed, tokens, limit)
+		return tx.Model(&b).Updates(map[string]any{"tokens": tokens, "updated_at": now}).Error
+	})
+	return result, err
+}
+
+// Stop 停止后台清理
+func (s *DBLimiterStore) Stop() {
+	s.cancel()
+}
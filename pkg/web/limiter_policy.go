@@ -0,0 +1,150 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// 限流维度
+const (
+	RateLimitKeyIP    = "ip"
+	RateLimitKeyUID   = "uid"
+	RateLimitKeyRoute = "route"
+)
+
+// rateLimitSeq 区分 RateLimitByPolicy 实例，各实例的已执行策略与令牌桶互不影响
+var rateLimitSeq atomic.Int64
+
+// RateLimitPolicy 路由限流策略
+type RateLimitPolicy struct {
+	Method string // 为空或 * 匹配全部方法
+	Path   string // gin 路由 FullPath，如 /users/:id；以 /* 结尾时前缀匹配；* 匹配全部
+	Key    string // 限流维度 ip/uid/route
+	Rate   Rate
+
+	keyFn KeyFunc
+}
+
+// NewRateLimitPolicy 创建限流策略
+// rate 格式为 数量/单位，单位支持 s/min/h，例如 5/min
+// burst 为 0 时与数量一致
+func NewRateLimitPolicy(method, path, key, rate string, burst int) (RateLimitPolicy, error) {
+	r, err := ParseRate(rate)
+	if err != nil {
+		return RateLimitPolicy{}, err
+	}
+	if burst > 0 {
+		r.Burst = burst
+	}
+	p := RateLimitPolicy{Method: strings.ToUpper(method), Path: path, Key: key, Rate: r}
+	switch key {
+	case RateLimitKeyIP:
+		p.keyFn = KeyByIP
+	case RateLimitKeyUID:
+		p.keyFn = KeyByUID
+	case RateLimitKeyRoute:
+		p.keyFn = KeyByRoute
+	default:
+		return RateLimitPolicy{}, fmt.Errorf("不支持的限流维度 %q", key)
+	}
+	if path == "" {
+		return RateLimitPolicy{}, fmt.Errorf("限流路由不能为空")
+	}
+	return p, nil
+}
+
+// ParseRate 解析 数量/单位，例如 5/min，10/s，100/h
+func ParseRate(s string) (Rate, error) {
+	num, unit, ok := strings.Cut(strings.ReplaceAll(s, " ", ""), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("限流速率 %q 格式错误，应为 数量/单位", s)
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("限流速率 %q 数量错误", s)
+	}
+	var d time.Duration
+	switch strings.ToLower(unit) {
+	case "s", "sec", "second":
+		d = time.Second
+	case "m", "min", "minute":
+		d = time.Minute
+	case "h", "hour":
+		d = time.Hour
+	case "d", "day":
+		d = 24 * time.Hour
+	default:
+		return Rate{}, fmt.Errorf("限流速率 %q 单位错误，支持 s/min/h/day", s)
+	}
+	return Rate{Limit: rate.Every(d / time.Duration(n)), Burst: n}, nil
+}
+
+func (p RateLimitPolicy) match(method, fullPath string) bool {
+	if p.Method != "" && p.Method != "*" && p.Method != method {
+		return false
+	}
+	if p.Path == "*" || p.Path == fullPath {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(p.Path, "*"); ok {
+		return strings.HasPrefix(fullPath, prefix)
+	}
+	return false
+}
+
+// RateLimitByPolicy 按策略表限流，通过 gin 的 FullPath 匹配路由，多个策略同时匹配时全部生效
+// 按 uid 限流的策略在未鉴权时跳过，可将同一个中间件再挂载到鉴权中间件之后，已执行的策略不会重复计数
+func RateLimitByPolicy(store LimiterStorer, policies ...RateLimitPolicy) gin.HandlerFunc {
+	id := strconv.FormatInt(rateLimitSeq.Add(1), 10)
+	doneKey := "rate_limit_done:" + id
+	return func(c *gin.Context) {
+		fullPath := c.FullPath()
+		if fullPath == "" {
+			c.Next()
+			return
+		}
+		done, _ := c.Get(doneKey)
+		doneSet, _ := done.(map[int]struct{})
+
+		var last *LimitResult
+		for i, p := range policies {
+			if _, ok := doneSet[i]; ok || !p.match(c.Request.Method, fullPath) {
+				continue
+			}
+			key := p.keyFn(c)
+			if key == "" {
+				continue
+			}
+			if doneSet == nil {
+				doneSet = make(map[int]struct{}, 2)
+				c.Set(doneKey, doneSet)
+			}
+			doneSet[i] = struct{}{}
+
+			result, err := store.Take(c, "policy:"+id+"."+strconv.Itoa(i)+"|"+key, p.Rate)
+			if err != nil {
+				slog.Error("rate limit", "key", key, "err", err)
+				continue
+			}
+			if !result.Allowed {
+				setRateLimitHeader(c, result)
+				AbortWithStatusJSON(c, ErrTooManyRequests)
+				return
+			}
+			if last == nil || result.Remaining < last.Remaining {
+				last = &result
+			}
+		}
+		if last != nil {
+			setRateLimitHeader(c, *last)
+		}
+		c.Next()
+	}
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"bytes"
+	"encoding/hex"
 	"io"
 	"log/slog"
 	"net/http"
@@ -29,9 +30,33 @@ func (w *bodyLogWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// 请求头中的 trace id
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceParent = "traceparent"
+)
+
 // Logger 第二个参数是否记录 请求与响应的 body。
+// 优先使用请求头 X-Request-ID 或 traceparent 中的 trace id，并通过 X-Request-ID 响应头返回
+// 通过 L(ctx) 获取携带 trace_id/uid/route/method 的请求日志
 func Logger(log *slog.Logger, recordBodyFn func(*gin.Context) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		now := time.Now()
+		// Tracing 中间件已写入时，与 span 保持一致
+		traceID, ok := TraceID(c)
+		if !ok {
+			traceID = requestTraceID(c.Request.Header)
+			SetTraceID(c, traceID)
+		}
+		c.Header(HeaderRequestID, traceID)
+
+		base := log.With(
+			"trace_id", traceID,
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+		)
+		SetLogger(c, base)
+
 		var reqBody string
 		var blw bodyLogWriter
 
@@ -41,7 +66,7 @@ func Logger(log *slog.Logger, recordBodyFn func(*gin.Context) bool) gin.HandlerF
 			// 请求参数
 			raw, err := c.GetRawData()
 			if err != nil {
-				slog.Error("logger", "err", err)
+				base.Error("logger", "err", err)
 			}
 			maxL := len(raw)
 			if maxL > 100 {
@@ -58,34 +83,65 @@ func Logger(log *slog.Logger, recordBodyFn func(*gin.Context) bool) gin.HandlerF
 			c.Writer = &blw
 		}
 
-		now := time.Now()
-		// traceid := trace.SpanContextFromContext(c.Request.Context()).TraceID().String()
-		SetTraceID(c, uuid.NewString())
 		c.Next()
 
+		// 鉴权后的请求日志已携带 uid
+		if l, ok := LoggerFromContext(c); ok {
+			base = l
+		}
 		code := c.Writer.Status()
 		out := []any{
-			"uid", uid,
-			"method", c.Request.Method,
 			"path", c.Request.URL.Path,
 			"query", c.Request.URL.RawQuery,
 			"remoteaddr", c.ClientIP(),
 			"statuscode", code,
 			"since", time.Since(now).Milliseconds(),
-			"trace_id", MustTraceID(c),
 		}
 		if recordBody {
 			out = append(out, []any{"request_body", reqBody, "response_body", blw.body.String()}...)
 		}
 		if code >= 200 && code < 400 {
-			log.Info("OK", out...)
+			base.Info("OK", out...)
 			return
 		}
 		// 约定: 返回给客户端的错误，记录的 key 为 responseErr
 		errStr, _ := c.Get("responseErr")
 		if !(code == 404 || code == 401) {
-			out = append(out, []any{"err", errStr})
+			out = append(out, "err", errStr)
+		}
+		if stack, ok := c.Get(responseStack); ok {
+			out = append(out, "stack", stack)
+		}
+		base.Warn("Bad", out...)
+	}
+}
+
+// requestTraceID 依次从 X-Request-ID、traceparent 读取，都不合法时生成新的 trace id
+func requestTraceID(h http.Header) string {
+	if v := h.Get(HeaderRequestID); validRequestID(v) {
+		return v
+	}
+	if sc, ok := ParseTraceParent(h.Get(HeaderTraceParent)); ok {
+		return sc.TraceID
+	}
+	return NewTraceID()
+}
+
+// NewTraceID 生成 32 位十六进制的 trace id，与 W3C trace context 兼容
+func NewTraceID() string {
+	id := uuid.New()
+	return hex.EncodeToString(id[:])
+}
+
+// validRequestID 限制长度与字符，避免日志注入
+func validRequestID(v string) bool {
+	if v == "" || len(v) > 128 {
+		return false
+	}
+	for _, r := range v {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_' || r == '.' || r == ':') {
+			return false
 		}
-		log.Warn("Bad", out...)
 	}
+	return true
 }
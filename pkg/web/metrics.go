@@ -4,6 +4,7 @@ import (
 	"expvar"
 	"runtime"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,31 +18,41 @@ import (
 // 4. HTTP 响应成功和错误的比率是多少?
 // 深入了解以上内容有助于把控程序，并得到预警。
 
-// Metrics ...
-func Metrics() gin.HandlerFunc {
-	request := expvar.NewInt("request")
-	totalRequests := expvar.NewInt("requests")
-	totalResponses := expvar.NewInt("responses")
-	urls := expvar.NewMap("requestURLs")
-	statusCodes := expvar.NewMap("statusCodes")
-	// 协程数量
-	go func() {
-		queue.NewCirQueue[expvar.Int](10)
-		// queue.CirQueue
-	}()
+type expvarMetrics struct {
+	request        *expvar.Int
+	totalRequests  *expvar.Int
+	totalResponses *expvar.Int
+	urls           *expvar.Map
+	statusCodes    *expvar.Map
+}
 
-	return func(c *gin.Context) {
-		totalRequests.Add(1)
-		request.Add(1)
-		c.Next()
-		request.Add(-1)
-		totalResponses.Add(1)
+// metricsVars expvar 的变量名不能重复注册，多次调用 Metrics 时共用
+var metricsVars = sync.OnceValue(func() *expvarMetrics {
+	return &expvarMetrics{
+		request:        expvar.NewInt("request"),
+		totalRequests:  expvar.NewInt("requests"),
+		totalResponses: expvar.NewInt("responses"),
+		urls:           expvar.NewMap("requestURLs"),
+		statusCodes:    expvar.NewMap("statusCodes"),
+	}
+})
 
-		status := c.Writer.Status()
-		if status != 404 {
-			urls.Add(c.Request.RequestURI, 1)
-		}
-		statusCodes.Add(strconv.Itoa(status), 1)
+// Metrics 请求指标，同时记录到 expvar 与 PrometheusHandler
+// 路由按 gin FullPath 统计，避免 /users/1 /users/2 各占一项
+func Metrics() gin.HandlerFunc {
+	vars := metricsVars()
+	return func(c *gin.Context) {
+		vars.totalRequests.Add(1)
+		vars.request.Add(1)
+		defer vars.request.Add(-1)
+		defaultHTTPMetrics.serve(c, func(route string, status int, since time.Duration) {
+			vars.totalResponses.Add(1)
+			if status != 404 {
+				vars.urls.Add(c.Request.Method+" "+route, 1)
+			}
+			vars.statusCodes.Add(strconv.Itoa(status), 1)
+			defaultLatency.Observe(route, c.Request.Method, status, since)
+		})
 	}
 }
 
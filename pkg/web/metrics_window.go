@@ -0,0 +1,287 @@
+package web
+
+import (
+	"cmp"
+	"expvar"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ixugo/goweb/pkg/queue"
+)
+
+// 流式直方图按指数划分桶，相对误差约 5%，覆盖 1µs 至 100s
+const (
+	sketchGamma   = 1.1
+	sketchBuckets = 194
+)
+
+var sketchLogGamma = math.Log(sketchGamma)
+
+type latencySketch struct {
+	counts [sketchBuckets]uint32
+	count  uint64
+	errors uint64
+}
+
+func (s *latencySketch) add(d time.Duration, isErr bool) {
+	us := float64(d) / float64(time.Microsecond)
+	idx := 0
+	if us > 1 {
+		idx = min(int(math.Log(us)/sketchLogGamma), sketchBuckets-1)
+	}
+	s.counts[idx]++
+	s.count++
+	if isErr {
+		s.errors++
+	}
+}
+
+func (s *latencySketch) merge(o *latencySketch) {
+	for i, v := range o.counts {
+		s.counts[i] += v
+	}
+	s.count += o.count
+	s.errors += o.errors
+}
+
+// quantile 返回桶的几何中值
+func (s *latencySketch) quantile(q float64) time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(s.count)))
+	var cumulative uint64
+	for i, v := range s.counts {
+		cumulative += uint64(v)
+		if cumulative >= max(rank, 1) {
+			us := math.Pow(sketchGamma, float64(i)+0.5)
+			return time.Duration(us * float64(time.Microsecond))
+		}
+	}
+	return 0
+}
+
+type windowSlot struct {
+	epoch  int64 // 时间戳 / 槽时长
+	sketch latencySketch
+}
+
+// slidingWindow 按时间分槽的环形缓冲区，过期的槽在写入时重置
+type slidingWindow struct {
+	slot  time.Duration
+	slots []windowSlot
+}
+
+func newSlidingWindow(slot time.Duration, n int) *slidingWindow {
+	return &slidingWindow{slot: slot, slots: make([]windowSlot, n)}
+}
+
+func (w *slidingWindow) add(now time.Time, d time.Duration, isErr bool) {
+	epoch := now.UnixNano() / int64(w.slot)
+	s := &w.slots[epoch%int64(len(w.slots))]
+	if s.epoch != epoch {
+		*s = windowSlot{epoch: epoch}
+	}
+	s.sketch.add(d, isErr)
+}
+
+// sum 合并最近 span 时长内的槽，包含当前未结束的槽
+func (w *slidingWindow) sum(now time.Time, span time.Duration, out *latencySketch) {
+	epoch := now.UnixNano() / int64(w.slot)
+	n := int64(span / w.slot)
+	for i := range w.slots {
+		s := &w.slots[i]
+		if s.epoch > epoch-n && s.epoch <= epoch {
+			out.merge(&s.sketch)
+		}
+	}
+}
+
+// 统计窗口，1m/5m 使用 10 秒的槽，1h 使用 1 分钟的槽
+var latencyWindows = []struct {
+	name  string
+	span  time.Duration
+	short bool
+}{
+	{"1m", time.Minute, true},
+	{"5m", 5 * time.Minute, true},
+	{"1h", time.Hour, false},
+}
+
+type routeWindow struct {
+	m     sync.Mutex
+	short *slidingWindow
+	long  *slidingWindow
+}
+
+func newRouteWindow() *routeWindow {
+	return &routeWindow{
+		short: newSlidingWindow(10*time.Second, 30),
+		long:  newSlidingWindow(time.Minute, 60),
+	}
+}
+
+func (r *routeWindow) add(now time.Time, d time.Duration, isErr bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.short.add(now, d, isErr)
+	r.long.add(now, d, isErr)
+}
+
+func (r *routeWindow) sum(now time.Time, span time.Duration, short bool, out *latencySketch) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if short {
+		r.short.sum(now, span, out)
+		return
+	}
+	r.long.sum(now, span, out)
+}
+
+// WindowStats 窗口内的统计，耗时单位毫秒
+type WindowStats struct {
+	Count     uint64  `json:"count"`      // 请求数
+	Errors    uint64  `json:"errors"`     // 5xx 响应数
+	ErrorRate float64 `json:"error_rate"` // 错误率
+	QPS       float64 `json:"qps"`        // 吞吐量
+	P50       float64 `json:"p50"`
+	P90       float64 `json:"p90"`
+	P99       float64 `json:"p99"`
+}
+
+func newWindowStats(s *latencySketch, span time.Duration) WindowStats {
+	out := WindowStats{
+		Count:  s.count,
+		Errors: s.errors,
+		QPS:    math.Round(float64(s.count)/span.Seconds()*1000) / 1000,
+		P50:    durationMs(s.quantile(0.5)),
+		P90:    durationMs(s.quantile(0.9)),
+		P99:    durationMs(s.quantile(0.99)),
+	}
+	if s.count > 0 {
+		out.ErrorRate = math.Round(float64(s.errors)/float64(s.count)*10000) / 10000
+	}
+	return out
+}
+
+func durationMs(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
+
+// RouteStats 路由在各个窗口内的统计
+type RouteStats struct {
+	Route   string                 `json:"route"`
+	Method  string                 `json:"method"`
+	Windows map[string]WindowStats `json:"windows"` // key 为 1m/5m/1h
+}
+
+// LatencyRecorder 按路由统计滑动窗口内的耗时分位数、错误率与吞吐量
+type LatencyRecorder struct {
+	m      sync.RWMutex
+	routes map[string]*routeWindow // key 为 method + " " + route
+	total  *routeWindow
+}
+
+// NewLatencyRecorder ...
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{routes: make(map[string]*routeWindow), total: newRouteWindow()}
+}
+
+// defaultLatency 由 Metrics 中间件记录
+var defaultLatency = NewLatencyRecorder()
+
+// Observe 记录一次请求，5xx 计为错误
+func (l *LatencyRecorder) Observe(route, method string, status int, d time.Duration) {
+	now := time.Now()
+	key := method + " " + route
+	l.m.RLock()
+	w, ok := l.routes[key]
+	l.m.RUnlock()
+	if !ok {
+		l.m.Lock()
+		if w, ok = l.routes[key]; !ok {
+			w = newRouteWindow()
+			l.routes[key] = w
+		}
+		l.m.Unlock()
+	}
+	isErr := status >= 500
+	w.add(now, d, isErr)
+	l.total.add(now, d, isErr)
+}
+
+// Routes 各路由的统计，按 1m 请求数倒序
+func (l *LatencyRecorder) Routes() []RouteStats {
+	now := time.Now()
+	l.m.RLock()
+	out := make([]RouteStats, 0, len(l.routes))
+	for k, w := range l.routes {
+		method, route, _ := strings.Cut(k, " ")
+		out = append(out, RouteStats{Route: route, Method: method, Windows: windowsStats(now, w)})
+	}
+	l.m.RUnlock()
+	slices.SortFunc(out, func(a, b RouteStats) int {
+		if c := cmp.Compare(b.Windows["1m"].Count, a.Windows["1m"].Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method+a.Route, b.Method+b.Route)
+	})
+	return out
+}
+
+// Total 所有路由合计的统计
+func (l *LatencyRecorder) Total() map[string]WindowStats {
+	return windowsStats(time.Now(), l.total)
+}
+
+func windowsStats(now time.Time, w *routeWindow) map[string]WindowStats {
+	out := make(map[string]WindowStats, len(latencyWindows))
+	for _, v := range latencyWindows {
+		var s latencySketch
+		w.sum(now, v.span, v.short, &s)
+		out[v.name] = newWindowStats(&s, v.span)
+	}
+	return out
+}
+
+// LatencyRoutes Metrics 中间件记录的各路由统计
+func LatencyRoutes() []RouteStats {
+	return defaultLatency.Routes()
+}
+
+// LatencyTotal Metrics 中间件记录的合计统计
+func LatencyTotal() map[string]WindowStats {
+	return defaultLatency.Total()
+}
+
+type LatencyHistory struct {
+	Time string `json:"time"`
+	WindowStats
+}
+
+// CountLatency 耗时统计，间隔 duration 记录一次最近 1 分钟的合计
+func CountLatency(d time.Duration, num uint8) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	history := queue.NewCirQueue[LatencyHistory](num)
+	var m sync.Mutex
+
+	expvar.Publish("latency_history", expvar.Func(func() any {
+		m.Lock()
+		defer m.Unlock()
+		return history.Range()
+	}))
+
+	for {
+		<-ticker.C
+		m.Lock()
+		history.Push(LatencyHistory{
+			Time:        time.Now().Format(time.DateTime),
+			WindowStats: LatencyTotal()["1m"],
+		})
+		m.Unlock()
+	}
+}
@@ -1,14 +1,26 @@
 package web
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
 )
 
 func TestLimiter(t *testing.T) {
@@ -29,6 +41,94 @@ func TestLimiter(t *testing.T) {
 	}
 }
 
+func TestLimiterStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	mem := NewMemoryLimiterStore(10*time.Millisecond, 50*time.Millisecond)
+	defer mem.Stop()
+	dbs := NewDBLimiterStore(db, time.Minute, time.Minute).AutoMigrate(true)
+	defer dbs.Stop()
+
+	for name, store := range map[string]LimiterStorer{"memory": mem, "db": dbs} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := Rate{Limit: rate.Every(time.Second), Burst: 2}
+			for i := range 2 {
+				v, err := store.Take(ctx, "a", limit)
+				require.NoError(t, err)
+				require.True(t, v.Allowed)
+				require.Equal(t, 1-i, v.Remaining)
+			}
+			v, err := store.Take(ctx, "a", limit)
+			require.NoError(t, err)
+			require.False(t, v.Allowed)
+			require.Positive(t, v.RetryAfter)
+			require.LessOrEqual(t, v.RetryAfter, time.Second)
+
+			// 不同 key 互不影响
+			v, err = store.Take(ctx, "b", limit)
+			require.NoError(t, err)
+			require.True(t, v.Allowed)
+		})
+	}
+
+	// 空闲的令牌桶被清理
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, mem.Len())
+}
+
+func TestRateLimitKey(t *testing.T) {
+	store := NewMemoryLimiterStore(time.Minute, time.Minute)
+	defer store.Stop()
+
+	r := gin.New()
+	limit := Rate{Limit: rate.Every(time.Hour), Burst: 1}
+	r.GET("/a", RateLimit(store, limit, KeyJoin(KeyByRoute, KeyByIP)), func(c *gin.Context) {})
+	r.GET("/b", RateLimit(store, limit, KeyJoin(KeyByRoute, KeyByIP)), func(c *gin.Context) {})
+	r.GET("/uid", RateLimit(store, limit, KeyByUID), func(c *gin.Context) {})
+
+	request := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, request("/a"))
+	require.NotEqual(t, http.StatusOK, request("/a"))
+	// 伪造 X-Forwarded-For 不能绕过限流
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, http.StatusOK, request("/b"))
+	// 未鉴权时不按用户限流
+	require.Equal(t, http.StatusOK, request("/uid"))
+	require.Equal(t, http.StatusOK, request("/uid"))
+}
+
+func TestRateLimitHeader(t *testing.T) {
+	r := gin.New()
+	r.GET("/", RateLimiter(rate.Every(10*time.Second), 2), func(c *gin.Context) {})
+
+	for i := range 3 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		if i < 2 {
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, strconv.Itoa(1-i), w.Header().Get("RateLimit-Remaining"))
+			require.Empty(t, w.Header().Get("Retry-After"))
+			continue
+		}
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+		require.Equal(t, "20", w.Header().Get("RateLimit-Reset"))
+		require.Equal(t, "10", w.Header().Get("Retry-After"))
+		require.Equal(t, ErrTooManyRequests.Reason(), Unmarshal(w.Body.Bytes()).Reason)
+	}
+}
+
 func BenchmarkResponse(b *testing.B) {
 	r := gin.New()
 	r.Use(RecordResponse())
@@ -43,3 +143,223 @@ func BenchmarkResponse(b *testing.B) {
 		}
 	})
 }
+
+func TestParseRate(t *testing.T) {
+	r, err := ParseRate("5/min")
+	require.NoError(t, err)
+	require.Equal(t, 5, r.Burst)
+	require.Equal(t, rate.Every(12*time.Second), r.Limit)
+
+	for _, v := range []string{"5", "0/s", "a/s", "5/week"} {
+		_, err := ParseRate(v)
+		require.Error(t, err, v)
+	}
+}
+
+func TestRateLimitByPolicy(t *testing.T) {
+	login, err := NewRateLimitPolicy("post", "/login", RateLimitKeyIP, "2/min", 0)
+	require.NoError(t, err)
+	export, err := NewRateLimitPolicy("GET", "/export/*", RateLimitKeyUID, "1/min", 0)
+	require.NoError(t, err)
+	store := NewMemoryLimiterStore(time.Minute, time.Minute)
+	defer store.Stop()
+	limiter := RateLimitByPolicy(store, login, export)
+
+	const secret = "test_secret_key"
+	r := gin.New()
+	r.Use(limiter)
+	r.POST("/login", func(c *gin.Context) {})
+	r.GET("/login", func(c *gin.Context) {})
+	r.GET("/export/:id", AuthMiddleware(secret), limiter, func(c *gin.Context) {})
+
+	request := func(method, path string, uid int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if uid > 0 {
+			token, _ := NewToken(TokenInput{UID: uid, Secret: secret})
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/login", 0).Code)
+	w := request(http.MethodPost, "/login", 0)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, http.StatusTooManyRequests, request(http.MethodPost, "/login", 0).Code)
+	// 方法不匹配
+	require.Equal(t, http.StatusOK, request(http.MethodGet, "/login", 0).Code)
+
+	// 按用户限流，同一中间件挂载两次不会重复计数
+	require.Equal(t, http.StatusOK, request(http.MethodGet, "/export/1", 1).Code)
+	require.Equal(t, http.StatusTooManyRequests, request(http.MethodGet, "/export/2", 1).Code)
+	require.Equal(t, http.StatusOK, request(http.MethodGet, "/export/1", 2).Code)
+
+	// 不同实例互不影响
+	all, err := NewRateLimitPolicy("*", "*", RateLimitKeyIP, "100/min", 0)
+	require.NoError(t, err)
+	report, err := NewRateLimitPolicy("GET", "/report", RateLimitKeyRoute, "1/min", 0)
+	require.NoError(t, err)
+	r2 := gin.New()
+	r2.Use(RateLimitByPolicy(store, all))
+	r2.GET("/report", RateLimitByPolicy(store, report), func(c *gin.Context) {})
+	for i, code := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r2.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/report", nil))
+		require.Equal(t, code, w.Code, i)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	const secret = "test_secret_key"
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := gin.New()
+	r.Use(Logger(log, func(*gin.Context) bool { return false }))
+	// 重复挂载鉴权中间件，uid 也只记录一次
+	r.GET("/users/:id", AuthMiddleware(secret), AuthMiddleware(secret), func(c *gin.Context) {
+		L(c.Request.Context()).Info("handler")
+	})
+
+	token, _ := NewToken(TokenInput{UID: 7, Secret: secret})
+	do := func(header map[string]string) *httptest.ResponseRecorder {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	lines := func() []map[string]any {
+		var out []map[string]any
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			var v map[string]any
+			require.NoError(t, json.Unmarshal(line, &v))
+			out = append(out, v)
+		}
+		return out
+	}
+
+	w := do(map[string]string{HeaderRequestID: "req-1"})
+	require.Equal(t, "req-1", w.Header().Get(HeaderRequestID))
+	logs := lines()
+	require.Len(t, logs, 2)
+	require.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(`"uid":`)))
+	for _, v := range logs {
+		require.Equal(t, "req-1", v["trace_id"])
+		require.EqualValues(t, 7, v["uid"])
+		require.Equal(t, "/users/:id", v["route"])
+		require.Equal(t, http.MethodGet, v["method"])
+	}
+
+	w = do(map[string]string{HeaderTraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"})
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", w.Header().Get(HeaderRequestID))
+
+	// 非法的请求头重新生成
+	w = do(map[string]string{HeaderRequestID: "a b\n", HeaderTraceParent: "00-xyz"})
+	require.Len(t, w.Header().Get(HeaderRequestID), 32)
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+
+	// 重置全局的请求指标，便于 -count 多次运行
+	defer func(h *HTTPMetrics) { defaultHTTPMetrics = h }(defaultHTTPMetrics)
+	defaultHTTPMetrics = NewHTTPMetrics(nil)
+
+	r := gin.New()
+	r.Use(Metrics())
+	// 多次调用不会重复注册 expvar
+	require.NotPanics(t, func() { Metrics() })
+	r.GET("/users/:id", func(c *gin.Context) {})
+	r.GET("/metrics", PrometheusHandler(DBStatsCollector(map[string]*sql.DB{"default": sqlDB, "log": sqlDB})))
+
+	for _, path := range []string{"/users/1", "/users/2", "/none"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+
+	body := w.Body.String()
+	require.Contains(t, body, `http_requests_total{route="/users/:id",method="GET",status="200"} 2`)
+	require.Contains(t, body, `http_requests_total{route="NotFound",method="GET",status="404"} 1`)
+	require.Contains(t, body, `http_request_duration_seconds_bucket{route="/users/:id",method="GET",status="200",le="+Inf"} 2`)
+	require.Contains(t, body, `http_request_duration_seconds_count{route="/users/:id",method="GET",status="200"} 2`)
+	require.Contains(t, body, "http_requests_in_flight 1")
+	require.Contains(t, body, "# TYPE go_goroutines gauge")
+	require.Contains(t, body, `go_sql_open_connections{db="default"}`)
+	require.Contains(t, body, `go_sql_open_connections{db="log"}`)
+	require.Equal(t, 1, strings.Count(body, "# TYPE go_sql_open_connections gauge"))
+	require.NotContains(t, body, "/users/1")
+}
+
+func TestHTTPMetricsMiddleware(t *testing.T) {
+	h := NewHTTPMetrics(nil)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		defer func() {
+			if recover() != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		c.Next()
+	})
+	// 重复挂载只记录一次
+	r.Use(h.Middleware(), h.Middleware())
+	r.GET("/", func(c *gin.Context) {})
+	r.GET("/panic", func(c *gin.Context) { panic("x") })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	require.Zero(t, h.inFlight.Load())
+	var buf bytes.Buffer
+	h.WritePrometheus(&buf)
+	require.Contains(t, buf.String(), `http_requests_total{route="/",method="GET",status="200"} 1`)
+}
+
+func TestPromLabels(t *testing.T) {
+	require.Equal(t, `{a="x\"y\\z\n"}`, promLabels("a", "x\"y\\z\n"))
+}
+
+func TestLatencySketch(t *testing.T) {
+	var s latencySketch
+	for i := 1; i <= 1000; i++ {
+		s.add(time.Duration(i)*time.Millisecond, i > 990)
+	}
+	require.EqualValues(t, 1000, s.count)
+	require.EqualValues(t, 10, s.errors)
+	for q, want := range map[float64]time.Duration{0.5: 500 * time.Millisecond, 0.9: 900 * time.Millisecond, 0.99: 990 * time.Millisecond} {
+		got := s.quantile(q)
+		require.InEpsilon(t, float64(want), float64(got), 0.06, "q=%v got=%v", q, got)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	w := newRouteWindow()
+	now := time.Now()
+	w.add(now.Add(-2*time.Minute), 10*time.Millisecond, false)
+	w.add(now.Add(-30*time.Minute), 10*time.Millisecond, true)
+	w.add(now, 10*time.Millisecond, true)
+
+	stats := windowsStats(now, w)
+	require.EqualValues(t, 1, stats["1m"].Count)
+	require.EqualValues(t, 2, stats["5m"].Count)
+	require.EqualValues(t, 3, stats["1h"].Count)
+	require.EqualValues(t, 2, stats["1h"].Errors)
+	require.InDelta(t, 0.5, stats["5m"].ErrorRate, 0.001)
+	require.InDelta(t, 10, stats["1m"].P99, 0.6)
+
+	// 槽被复用时重置
+	w.add(now.Add(5*time.Minute), time.Millisecond, false)
+	require.EqualValues(t, 1, windowsStats(now.Add(5*time.Minute), w)["5m"].Count)
+}
@@ -0,0 +1,587 @@
+package web
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteOption 接口文档选项
+type RouteOption func(*Operation)
+
+// WithSummary 接口简介
+func WithSummary(s string) RouteOption {
+	return func(o *Operation) { o.Summary = s }
+}
+
+// WithDescription 接口说明
+func WithDescription(s string) RouteOption {
+	return func(o *Operation) { o.Description = s }
+}
+
+// WithTags 接口分组
+func WithTags(tags ...string) RouteOption {
+	return func(o *Operation) { o.Tags = append(o.Tags, tags...) }
+}
+
+// WithErrors 接口可能返回的错误，按 http status code 分组写入文档
+func WithErrors(errs ...*Error) RouteOption {
+	return func(o *Operation) { o.Errors = append(o.Errors, errs...) }
+}
+
+// Operation 接口信息
+type Operation struct {
+	Method      string
+	Path        string // gin 路由，如 /users/:id
+	Summary     string
+	Description string
+	Tags        []string
+	Errors      []*Error
+	Input       reflect.Type
+	Output      reflect.Type
+}
+
+// OpenAPI 接口注册表，生成 OpenAPI 3.1 文档
+type OpenAPI struct {
+	Title   string
+	Version string
+
+	m   sync.RWMutex
+	ops []Operation
+}
+
+// NewOpenAPI ...
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{Title: title, Version: version}
+}
+
+// DefaultOpenAPI Handle 注册的接口
+var DefaultOpenAPI = NewOpenAPI("goweb", "1.0.0")
+
+// Add 记录接口，method 与 path 相同时覆盖已记录的接口
+func (a *OpenAPI) Add(op Operation) {
+	op.Method = strings.ToUpper(op.Method)
+	a.m.Lock()
+	defer a.m.Unlock()
+	i := slices.IndexFunc(a.ops, func(v Operation) bool { return v.Method == op.Method && v.Path == op.Path })
+	if i >= 0 {
+		a.ops[i] = op
+		return
+	}
+	a.ops = append(a.ops, op)
+}
+
+// Operations 已注册的接口
+func (a *OpenAPI) Operations() []Operation {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	return slices.Clone(a.ops)
+}
+
+// Handle 使用 WarpH 注册路由，并将入参、出参记录到 DefaultOpenAPI
+// r 为 *gin.RouterGroup 时，路径包含分组前缀
+func Handle[I any, O any](r gin.IRoutes, method, relativePath string, fn func(*gin.Context, *I) (O, error), opts ...RouteOption) {
+	r.Handle(method, relativePath, WarpH(fn))
+
+	fullPath := relativePath
+	if g, ok := r.(interface{ BasePath() string }); ok {
+		fullPath = joinPath(g.BasePath(), relativePath)
+	}
+	op := Operation{
+		Method: method,
+		Path:   fullPath,
+		Input:  reflect.TypeOf((*I)(nil)).Elem(),
+		Output: reflect.TypeOf((*O)(nil)).Elem(),
+	}
+	for _, fn := range opts {
+		fn(&op)
+	}
+	DefaultOpenAPI.Add(op)
+}
+
+func joinPath(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	p := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// OpenAPIHandler 输出 DefaultOpenAPI 的文档
+func OpenAPIHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, DefaultOpenAPI.Document())
+	}
+}
+
+// OpenAPIViewer 简易的文档查看页面，specURL 为 OpenAPIHandler 的地址
+func OpenAPIViewer(specURL string) gin.HandlerFunc {
+	page := strings.Replace(openAPIViewerHTML, "{{spec}}", jsString(specURL), 1)
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	}
+}
+
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Document 生成 OpenAPI 3.1 文档
+func (a *OpenAPI) Document() map[string]any {
+	s := newSchemaBuilder()
+	paths := make(map[string]map[string]any)
+	for _, op := range a.Operations() {
+		p := ginParam.ReplaceAllString(op.Path, "{$1}")
+		if paths[p] == nil {
+			paths[p] = make(map[string]any)
+		}
+		paths[p][strings.ToLower(op.Method)] = s.operation(op)
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info":    map[string]any{"title": a.Title, "version": a.Version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": s.components,
+		},
+	}
+}
+
+type schemaBuilder struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	s := schemaBuilder{components: make(map[string]any), names: make(map[reflect.Type]string)}
+	s.components["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"reason":   map[string]any{"type": "string"},
+			"msg":      map[string]any{"type": "string"},
+			"details":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"trace_id": map[string]any{"type": "string"},
+			"fields": map[string]any{"type": "array", "items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"field": map[string]any{"type": "string"},
+					"rule":  map[string]any{"type": "string"},
+					"param": map[string]any{"type": "string"},
+					"msg":   map[string]any{"type": "string"},
+				},
+			}},
+		},
+	}
+	return &s
+}
+
+// inputField 入参字段，嵌入的结构体已展开
+type inputField struct {
+	field reflect.StructField
+	json  string
+	form  string
+	uri   string
+	head  string
+}
+
+func inputFields(t reflect.Type) []inputField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var out []inputField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && indirect(f.Type).Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			out = append(out, inputFields(f.Type)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		out = append(out, inputField{
+			field: f,
+			json:  tagName(f, "json"),
+			form:  tagName(f, "form"),
+			uri:   tagName(f, "uri"),
+			head:  tagName(f, "header"),
+		})
+	}
+	return out
+}
+
+func tagName(f reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// hasBody 含有 json 字段或文件字段时存在请求体，Bind 不区分请求方法
+func hasBody(fields []inputField) bool {
+	return slices.ContainsFunc(fields, func(f inputField) bool {
+		return f.uri == "" && (f.json != "" || isFile(f.field.Type))
+	})
+}
+
+func (s *schemaBuilder) operation(op Operation) map[string]any {
+	out := map[string]any{
+		"operationId": operationID(op),
+		"responses":   s.responses(op),
+	}
+	if op.Summary != "" {
+		out["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		out["tags"] = op.Tags
+	}
+
+	fields := inputFields(op.Input)
+	withBody := hasBody(fields)
+	// 含有文件字段时请求体为 multipart 表单，使用 form 标签
+	multipartBody := withBody && slices.ContainsFunc(fields, func(f inputField) bool { return isFile(f.field.Type) })
+
+	var params []any
+	body := map[string]any{}
+	var bodyRequired []string
+	for _, f := range fields {
+		required := isRequired(f.field)
+		schema := s.fieldSchema(f.field)
+		bodyName := f.json
+		if multipartBody {
+			bodyName = f.form
+			if bodyName == "" && isFile(f.field.Type) {
+				bodyName = f.field.Name
+			}
+		}
+		switch {
+		case f.uri != "":
+			params = append(params, map[string]any{"name": f.uri, "in": "path", "required": true, "schema": schema})
+		case withBody && bodyName != "":
+			body[bodyName] = schema
+			if required {
+				bodyRequired = append(bodyRequired, bodyName)
+			}
+		case f.form != "":
+			param := map[string]any{"name": f.form, "in": "query", "required": required, "schema": schema}
+			// name[key]=value
+			if indirect(f.field.Type).Kind() == reflect.Map {
+				param["style"] = "deepObject"
+				param["explode"] = true
+			}
+			params = append(params, param)
+		case f.head != "":
+			params = append(params, map[string]any{"name": f.head, "in": "header", "required": required, "schema": schema})
+		}
+	}
+	// 路由中的参数都必须声明，未通过 uri 字段声明的按字符串处理
+	for _, m := range ginParam.FindAllStringSubmatch(op.Path, -1) {
+		declared := slices.ContainsFunc(fields, func(f inputField) bool { return f.uri == m[1] })
+		if !declared {
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+	if len(body) > 0 {
+		schema := map[string]any{"type": "object", "properties": body}
+		if len(bodyRequired) > 0 {
+			schema["required"] = bodyRequired
+		}
+		contentType := "application/json"
+		if multipartBody {
+			contentType = "multipart/form-data"
+		}
+		out["requestBody"] = map[string]any{
+			"required": len(bodyRequired) > 0,
+			"content":  map[string]any{contentType: map[string]any{"schema": schema}},
+		}
+	}
+	return out
+}
+
+func operationID(op Operation) string {
+	p := ginParam.ReplaceAllString(op.Path, "by_$1")
+	p = strings.NewReplacer("/", "_", "-", "_", ".", "_").Replace(strings.Trim(p, "/"))
+	return strings.ToLower(op.Method) + "_" + p
+}
+
+func (s *schemaBuilder) responses(op Operation) map[string]any {
+	out := make(map[string]any)
+	ok := map[string]any{"description": "OK"}
+	if t := op.Output; t != nil && t.Kind() != reflect.Interface && !(t.Kind() == reflect.Struct && t.NumField() == 0) {
+		ok["content"] = map[string]any{"application/json": map[string]any{"schema": s.schema(t)}}
+	} else if t != nil && t.Kind() == reflect.Interface {
+		ok["content"] = map[string]any{"application/json": map[string]any{"schema": map[string]any{}}}
+	}
+	out["200"] = ok
+
+	errs := slices.Clone(op.Errors)
+	if op.Input != nil && op.Input.Size() != 0 {
+		errs = append([]*Error{ErrBadRequest}, errs...)
+	}
+	group := make(map[int][]string)
+	for _, e := range errs {
+		code := e.HTTPCode()
+		if !slices.Contains(group[code], e.Reason()) {
+			group[code] = append(group[code], e.Reason())
+		}
+	}
+	for code, reasons := range group {
+		out[strconv.Itoa(code)] = map[string]any{
+			"description": strings.Join(reasons, ", "),
+			"content": map[string]any{"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+			}},
+		}
+	}
+	return out
+}
+
+func isRequired(f reflect.StructField) bool {
+	return slices.Contains(strings.Split(f.Tag.Get("binding"), ","), "required")
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	fileType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+func isFile(t reflect.Type) bool {
+	t = indirect(t)
+	if t.Kind() == reflect.Slice {
+		t = indirect(t.Elem())
+	}
+	return t == fileType
+}
+
+// fieldSchema 字段 schema，附加 binding 与 form 标签中的约束
+func (s *schemaBuilder) fieldSchema(f reflect.StructField) map[string]any {
+	schema := s.schema(f.Type)
+	if _, ok := schema["$ref"]; ok {
+		return schema
+	}
+	isString := schema["type"] == "string"
+	isNumber := schema["type"] == "integer" || schema["type"] == "number"
+	for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "oneof":
+			enum := make([]any, 0, 4)
+			for _, v := range strings.Fields(param) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && isNumber {
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, v)
+				}
+			}
+			schema["enum"] = enum
+		case "min", "gte", "max", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			isMin := name == "min" || name == "gte"
+			switch {
+			case isString && isMin:
+				schema["minLength"] = n
+			case isString:
+				schema["maxLength"] = n
+			case isNumber && isMin:
+				schema["minimum"] = n
+			case isNumber:
+				schema["maximum"] = n
+			}
+		case "email":
+			schema["format"] = "email"
+		case "url":
+			schema["format"] = "uri"
+		case "uuid":
+			schema["format"] = "uuid"
+		}
+	}
+	if _, opts, ok := strings.Cut(f.Tag.Get("form"), ","); ok {
+		for _, opt := range strings.Split(opts, ",") {
+			if v, ok := strings.CutPrefix(opt, "default="); ok {
+				schema["default"] = v
+			}
+		}
+	}
+	if v := f.Tag.Get("comment"); v != "" {
+		schema["description"] = v
+	}
+	return schema
+}
+
+var schemaNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// schema 命名的结构体写入 components，返回 $ref
+func (s *schemaBuilder) schema(t reflect.Type) map[string]any {
+	t = indirect(t)
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case fileType:
+		return map[string]any{"type": "string", "format": "binary"}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = s.componentName(t)
+			s.names[t] = name
+			// 先占位，避免递归类型死循环
+			s.components[name] = map[string]any{}
+			s.components[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (s *schemaBuilder) componentName(t reflect.Type) string {
+	name := schemaNameInvalid.ReplaceAllString(t.Name(), "_")
+	if _, exist := s.components[name]; !exist {
+		return name
+	}
+	pkg := path.Base(t.PkgPath())
+	name = schemaNameInvalid.ReplaceAllString(pkg+"."+t.Name(), "_")
+	for i := 2; ; i++ {
+		if _, exist := s.components[name]; !exist {
+			return name
+		}
+		name = schemaNameInvalid.ReplaceAllString(pkg+"."+t.Name(), "_") + strconv.Itoa(i)
+	}
+}
+
+func (s *schemaBuilder) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" && indirect(f.Type).Kind() == reflect.Struct {
+				walk(indirect(f.Type))
+				continue
+			}
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = s.fieldSchema(f)
+			if isRequired(f) || (tag != "" && !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer) {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+	out := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+const openAPIViewerHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API</title>
+<style>
+body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;margin:0 auto;max-width:960px;padding:16px;color:#222}
+details{border:1px solid #ddd;border-radius:4px;margin:8px 0}
+summary{cursor:pointer;padding:8px;font-family:monospace}
+.m{display:inline-block;width:64px;font-weight:bold}
+pre{background:#f6f8fa;margin:0;padding:8px;overflow:auto}
+</style>
+</head>
+<body>
+<h2 id="title"></h2>
+<div id="paths"></div>
+<script>
+fetch({{spec}}).then(r => r.json()).then(doc => {
+  document.getElementById("title").textContent = doc.info.title + " " + doc.info.version;
+  const root = document.getElementById("paths");
+  for (const [p, ops] of Object.entries(doc.paths).sort()) {
+    for (const [m, op] of Object.entries(ops)) {
+      const d = document.createElement("details");
+      const s = document.createElement("summary");
+      s.innerHTML = '<span class="m"></span><span></span> <small></small>';
+      s.children[0].textContent = m.toUpperCase();
+      s.children[1].textContent = p;
+      s.children[2].textContent = op.summary || "";
+      const pre = document.createElement("pre");
+      pre.textContent = JSON.stringify(op, null, 2);
+      d.append(s, pre);
+      root.append(d);
+    }
+  }
+  const d = document.createElement("details");
+  d.innerHTML = "<summary>components</summary><pre></pre>";
+  d.children[1].textContent = JSON.stringify(doc.components, null, 2);
+  root.append(d);
+});
+</script>
+</body>
+</html>
+`
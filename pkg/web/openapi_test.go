@@ -0,0 +1,147 @@
+package web
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIUser struct {
+	ID        int            `json:"id"`
+	Name      string         `json:"name"`
+	Friends   []*openAPIUser `json:"friends,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	old := DefaultOpenAPI
+	DefaultOpenAPI = NewOpenAPI("test", "0.1.0")
+	defer func() { DefaultOpenAPI = old }()
+
+	type getInput struct {
+		ID    int    `uri:"id"`
+		Token string `header:"X-Token" binding:"required"`
+		Page  int    `form:"page,default=1" binding:"min=1"`
+	}
+	type createInput struct {
+		Name  string `json:"name" binding:"required,max=20"`
+		Role  string `json:"role" binding:"oneof=admin user"`
+		Trace string `form:"trace"`
+	}
+	type uploadInput struct {
+		Remark string                `form:"remark"`
+		File   *multipart.FileHeader `form:"file" binding:"required"`
+	}
+
+	r := gin.New()
+	g := r.Group("/users")
+	Handle(g, http.MethodGet, "/:id", func(_ *gin.Context, _ *getInput) (openAPIUser, error) { return openAPIUser{}, nil },
+		WithTags("user"), WithSummary("详情"), WithErrors(ErrNotFound))
+	Handle(g, http.MethodPost, "", func(_ *gin.Context, _ *createInput) (*openAPIUser, error) { return nil, nil },
+		WithErrors(ErrConflict))
+	Handle(r, http.MethodPut, "/files", func(_ *gin.Context, _ *uploadInput) (any, error) { return nil, nil })
+	type deleteInput struct {
+		Force bool `json:"force"`
+	}
+	Handle(r, http.MethodDelete, "/files/:name", func(_ *gin.Context, _ *deleteInput) (any, error) { return nil, nil })
+	r.GET("/openapi.json", OpenAPIHandler())
+
+	// 重复注册时覆盖
+	Handle(gin.New().Group("/users"), http.MethodGet, "/:id", func(_ *gin.Context, _ *getInput) (openAPIUser, error) { return openAPIUser{}, nil },
+		WithTags("user"), WithSummary("详情"), WithErrors(ErrNotFound))
+	require.Len(t, DefaultOpenAPI.Operations(), 4)
+
+	// 路由正常注册
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string   `json:"operationId"`
+			Tags        []string `json:"tags"`
+			Parameters  []struct {
+				Name     string         `json:"name"`
+				In       string         `json:"in"`
+				Required bool           `json:"required"`
+				Schema   map[string]any `json:"schema"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]any `json:"properties"`
+						Required   []string                  `json:"required"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Description string `json:"description"`
+				Content     map[string]struct {
+					Schema map[string]any `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	require.Equal(t, "3.1.0", doc.OpenAPI)
+
+	get := doc.Paths["/users/{id}"]["get"]
+	require.Equal(t, "get_users_by_id", get.OperationID)
+	require.Equal(t, []string{"user"}, get.Tags)
+	require.Len(t, get.Parameters, 3)
+	params := make(map[string]string)
+	for _, p := range get.Parameters {
+		params[p.Name] = p.In
+	}
+	require.Equal(t, map[string]string{"id": "path", "X-Token": "header", "page": "query"}, params)
+	require.EqualValues(t, "1", get.Parameters[2].Schema["default"])
+	require.EqualValues(t, 1, get.Parameters[2].Schema["minimum"])
+	require.Equal(t, "#/components/schemas/openAPIUser", get.Responses["200"].Content["application/json"].Schema["$ref"])
+	require.Equal(t, "ErrBadRequest", get.Responses["400"].Description)
+	require.Equal(t, "ErrNotFound", get.Responses["404"].Description)
+
+	user := doc.Components.Schemas["openAPIUser"]
+	require.Equal(t, []string{"id", "name", "created_at"}, user.Required)
+	require.Equal(t, "date-time", user.Properties["created_at"]["format"])
+	require.Equal(t, "int64", user.Properties["id"]["format"])
+	require.Equal(t, "#/components/schemas/openAPIUser", user.Properties["friends"]["items"].(map[string]any)["$ref"])
+
+	post := doc.Paths["/users"]["post"]
+	body := post.RequestBody.Content["application/json"].Schema
+	require.Equal(t, []string{"name"}, body.Required)
+	require.EqualValues(t, 20, body.Properties["name"]["maxLength"])
+	require.Equal(t, []any{"admin", "user"}, body.Properties["role"]["enum"])
+	require.Len(t, post.Parameters, 1)
+	require.Equal(t, "query", post.Parameters[0].In)
+	require.Equal(t, "ErrConflict", post.Responses["409"].Description)
+
+	upload := doc.Paths["/files"]["put"].RequestBody.Content["multipart/form-data"].Schema
+	require.Equal(t, "binary", upload.Properties["file"]["format"])
+	require.Contains(t, upload.Properties, "remark")
+	require.Equal(t, []string{"file"}, upload.Required)
+
+	// 未声明 uri 字段的路由参数
+	del := doc.Paths["/files/{name}"]["delete"]
+	require.Len(t, del.Parameters, 1)
+	require.Equal(t, "name", del.Parameters[0].Name)
+	require.Equal(t, "path", del.Parameters[0].In)
+	require.True(t, del.Parameters[0].Required)
+	// 是否存在请求体由入参字段决定，与请求方法无关
+	require.Contains(t, del.RequestBody.Content["application/json"].Schema.Properties, "force")
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentTypeProblem RFC 9457 problem details
+const ContentTypeProblem = "application/problem+json"
+
+// ErrorFormat 错误响应格式
+type ErrorFormat int
+
+const (
+	ErrorFormatDefault   ErrorFormat = iota // {reason,msg,details,trace_id}
+	ErrorFormatProblem                      // 全部使用 application/problem+json
+	ErrorFormatNegotiate                    // 请求头 Accept 包含 application/problem+json 时使用
+)
+
+var errorFormat = ErrorFormatDefault
+
+// SetErrorFormat 设置 Fail 与 AbortWithStatusJSON 的响应格式，应在启动时设置
+func SetErrorFormat(f ErrorFormat) {
+	errorFormat = f
+}
+
+// ProblemTypeBase problem 的 type 前缀，与 reason 拼接，例如 https://example.com/errors#
+// 为空时 type 为 about:blank，title 为 http 状态描述
+var ProblemTypeBase = ""
+
+func useProblem(c ResponseWriter) bool {
+	switch errorFormat {
+	case ErrorFormatProblem:
+		return true
+	case ErrorFormatNegotiate:
+		h, ok := c.(interface{ GetHeader(string) string })
+		return ok && acceptsProblem(h.GetHeader("Accept"))
+	}
+	return false
+}
+
+func acceptsProblem(accept string) bool {
+	for _, v := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil || mt != ContentTypeProblem {
+			continue
+		}
+		v, ok := params["q"]
+		if !ok {
+			return true
+		}
+		q, err := strconv.ParseFloat(v, 64)
+		return err == nil && q > 0
+	}
+	return false
+}
+
+// newProblem 将默认格式转换为 problem details
+// msg 作为 detail，其余字段作为扩展成员
+func newProblem(c ResponseWriter, code int, out map[string]any) map[string]any {
+	p := make(map[string]any, len(out)+5)
+	for k, v := range out {
+		if k != "msg" {
+			p[k] = v
+		}
+	}
+	p["status"] = code
+	p["type"] = "about:blank"
+	p["title"] = http.StatusText(code)
+	if reason, _ := out["reason"].(string); reason != "" && ProblemTypeBase != "" {
+		p["type"] = ProblemTypeBase + reason
+		if msg, ok := lookupMessage(reason, GetLocale(c)); ok {
+			p["title"] = msg
+		}
+	}
+	if msg, ok := out["msg"]; ok {
+		p["detail"] = msg
+	}
+	if gc, ok := c.(*gin.Context); ok && gc.Request != nil {
+		p["instance"] = gc.Request.URL.RequestURI()
+	}
+	return p
+}
+
+func writeProblem(c ResponseWriter, code int, out map[string]any, abort bool) {
+	p := newProblem(c, code, out)
+	gc, ok := c.(*gin.Context)
+	if !ok {
+		if abort {
+			c.AbortWithStatusJSON(code, p)
+		} else {
+			c.JSON(code, p)
+		}
+		return
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		b = []byte(`{"type":"about:blank","status":500}`)
+		code = http.StatusInternalServerError
+	}
+	if abort {
+		gc.Abort()
+	}
+	gc.Data(code, ContentTypeProblem, b)
+}
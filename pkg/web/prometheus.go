@@ -0,0 +1,268 @@
+package web
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromCollector 输出 Prometheus 文本格式的指标
+type PromCollector interface {
+	WritePrometheus(w io.Writer)
+}
+
+// PromCollectorFunc 函数适配 PromCollector
+type PromCollectorFunc func(w io.Writer)
+
+// WritePrometheus implements PromCollector.
+func (fn PromCollectorFunc) WritePrometheus(w io.Writer) {
+	fn(w)
+}
+
+// DefaultBuckets 请求耗时直方图的桶，单位秒
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type httpLabel struct {
+	route  string
+	method string
+	status string
+}
+
+type histogram struct {
+	counts []uint64 // 与 buckets 对应，非累计
+	count  uint64
+	sum    float64
+}
+
+// HTTPMetrics 请求计数、进行中请求数与耗时直方图，按 gin FullPath、method、status 区分
+type HTTPMetrics struct {
+	buckets  []float64
+	inFlight atomic.Int64
+	ctxKey   string // 标记请求已记录，重复挂载时不重复计数
+
+	m         sync.Mutex
+	histogram map[httpLabel]*histogram
+}
+
+// NewHTTPMetrics buckets 为空时使用 DefaultBuckets
+func NewHTTPMetrics(buckets []float64) *HTTPMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	h := HTTPMetrics{buckets: buckets, histogram: make(map[httpLabel]*histogram)}
+	h.ctxKey = fmt.Sprintf("http_metrics_%p", &h)
+	return &h
+}
+
+// defaultHTTPMetrics 由 Metrics 中间件记录
+var defaultHTTPMetrics = NewHTTPMetrics(nil)
+
+// Middleware 记录请求指标
+func (h *HTTPMetrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.serve(c, nil)
+	}
+}
+
+// serve 执行后续处理并记录指标，请求结束后以路由、状态码、耗时回调 fn
+// 同一实例对同一请求只记录一次
+func (h *HTTPMetrics) serve(c *gin.Context, fn func(route string, status int, d time.Duration)) {
+	now := time.Now()
+	_, recorded := c.Get(h.ctxKey)
+	if !recorded {
+		c.Set(h.ctxKey, struct{}{})
+		h.inFlight.Add(1)
+		defer h.inFlight.Add(-1)
+	}
+	c.Next()
+	route, status, d := routeName(c), c.Writer.Status(), time.Since(now)
+	if !recorded {
+		h.Observe(route, c.Request.Method, status, d)
+	}
+	if fn != nil {
+		fn(route, status, d)
+	}
+}
+
+// Observe 记录一次请求
+func (h *HTTPMetrics) Observe(route, method string, status int, d time.Duration) {
+	key := httpLabel{route: route, method: method, status: strconv.Itoa(status)}
+	v := d.Seconds()
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	hist, ok := h.histogram[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.histogram[key] = hist
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		hist.counts[i]++
+	}
+	hist.count++
+	hist.sum += v
+}
+
+// WritePrometheus implements PromCollector.
+func (h *HTTPMetrics) WritePrometheus(w io.Writer) {
+	h.m.Lock()
+	keys := make([]httpLabel, 0, len(h.histogram))
+	hists := make(map[httpLabel]histogram, len(h.histogram))
+	for k, v := range h.histogram {
+		keys = append(keys, k)
+		hists[k] = histogram{counts: slices.Clone(v.counts), count: v.count, sum: v.sum}
+	}
+	h.m.Unlock()
+	slices.SortFunc(keys, func(a, b httpLabel) int {
+		return strings.Compare(a.route+" "+a.method+" "+a.status, b.route+" "+b.method+" "+b.status)
+	})
+
+	writeHeader(w, "http_requests_in_flight", "gauge", "当前正在处理的请求数")
+	fmt.Fprintf(w, "http_requests_in_flight %d\n", h.inFlight.Load())
+
+	writeHeader(w, "http_requests_total", "counter", "请求总数")
+	for _, k := range keys {
+		fmt.Fprintf(w, "http_requests_total%s %d\n", k.labels(), hists[k].count)
+	}
+
+	writeHeader(w, "http_request_duration_seconds", "histogram", "请求耗时")
+	for _, k := range keys {
+		v := hists[k]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += v.counts[i]
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket%s %d\n", k.labels("le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket%s %d\n", k.labels("le", "+Inf"), v.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum%s %s\n", k.labels(), formatFloat(v.sum))
+		fmt.Fprintf(w, "http_request_duration_seconds_count%s %d\n", k.labels(), v.count)
+	}
+}
+
+func (l httpLabel) labels(kv ...string) string {
+	return promLabels(append([]string{"route", l.route, "method", l.method, "status", l.status}, kv...)...)
+}
+
+// routeName 未匹配路由的请求统一记为 NotFound，避免标签基数膨胀
+func routeName(c *gin.Context) string {
+	if v := c.FullPath(); v != "" {
+		return v
+	}
+	return "NotFound"
+}
+
+// GoCollector Go 运行时指标
+func GoCollector() PromCollector {
+	return PromCollectorFunc(func(w io.Writer) {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+
+		writeHeader(w, "go_info", "gauge", "Go 版本")
+		fmt.Fprintf(w, "go_info%s 1\n", promLabels("version", runtime.Version()))
+		writeGauge(w, "go_goroutines", "协程数量", float64(runtime.NumGoroutine()))
+		writeGauge(w, "go_memstats_alloc_bytes", "堆上已分配且仍在使用的字节数", float64(stats.Alloc))
+		writeGauge(w, "go_memstats_sys_bytes", "从系统获取的字节数", float64(stats.Sys))
+		writeGauge(w, "go_memstats_heap_inuse_bytes", "堆上正在使用的字节数", float64(stats.HeapInuse))
+		writeGauge(w, "go_memstats_heap_objects", "堆上的对象数量", float64(stats.HeapObjects))
+		writeHeader(w, "go_gc_cycles_total", "counter", "gc 次数")
+		fmt.Fprintf(w, "go_gc_cycles_total %d\n", stats.NumGC)
+		writeHeader(w, "go_gc_pause_seconds_total", "counter", "gc 暂停总时长")
+		fmt.Fprintf(w, "go_gc_pause_seconds_total %s\n", formatFloat(time.Duration(stats.PauseTotalNs).Seconds()))
+	})
+}
+
+// DBStatsCollector 数据库连接池指标，key 为数据库名称，用于区分多个数据库
+// gorm 使用 db.DB() 获取 *sql.DB，多个数据库应放在同一个 collector 中，避免重复输出 TYPE
+func DBStatsCollector(dbs map[string]*sql.DB) PromCollector {
+	names := make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	type metric struct {
+		name, typ, help string
+		value           func(sql.DBStats) float64
+	}
+	metrics := []metric{
+		{"go_sql_max_open_connections", "gauge", "最大连接数", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"go_sql_open_connections", "gauge", "当前连接数", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"go_sql_in_use_connections", "gauge", "使用中的连接数", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"go_sql_idle_connections", "gauge", "空闲连接数", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"go_sql_wait_count_total", "counter", "等待连接的次数", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"go_sql_wait_duration_seconds_total", "counter", "等待连接的总时长", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"go_sql_max_idle_closed_total", "counter", "因超过最大空闲数关闭的连接数", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"go_sql_max_idle_time_closed_total", "counter", "因超过最大空闲时间关闭的连接数", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"go_sql_max_lifetime_closed_total", "counter", "因超过最大存活时间关闭的连接数", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	return PromCollectorFunc(func(w io.Writer) {
+		stats := make([]sql.DBStats, len(names))
+		for i, name := range names {
+			stats[i] = dbs[name].Stats()
+		}
+		for _, m := range metrics {
+			writeHeader(w, m.name, m.typ, m.help)
+			for i, name := range names {
+				fmt.Fprintf(w, "%s%s %s\n", m.name, promLabels("db", name), formatFloat(m.value(stats[i])))
+			}
+		}
+	})
+}
+
+// PrometheusHandler 输出 Prometheus 文本格式，包含 Metrics 中间件记录的请求指标与 Go 运行时指标
+func PrometheusHandler(collectors ...PromCollector) gin.HandlerFunc {
+	collectors = append([]PromCollector{defaultHTTPMetrics, GoCollector()}, collectors...)
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := bufio.NewWriter(c.Writer)
+		for _, v := range collectors {
+			v.WritePrometheus(w)
+		}
+		_ = w.Flush()
+	}
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeGauge(w io.Writer, name, help string, v float64) {
+	writeHeader(w, name, "gauge", help)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabels 按 key value 成对生成 {k="v",...}
+func promLabels(kv ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(kv[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(kv[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
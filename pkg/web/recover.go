@@ -2,6 +2,7 @@ package web
 
 import (
 	"fmt"
+	"net/http"
 	"runtime/debug"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +16,10 @@ func Recover() gin.HandlerFunc {
 		// variable after the fact.
 		defer func() {
 			if rec := recover(); rec != nil {
+				// 由 http.Server 中断连接
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
 
 				// Stack trace will be provided.
 				trace := debug.Stack()
@@ -3,10 +3,15 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 	"unsafe"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ixugo/goweb/pkg/orm"
+	"gorm.io/gorm"
 	// errors "github.com/go-kratos/kratos/v2/errors"
 )
 
@@ -33,7 +38,10 @@ type ResponseWriter interface {
 	AbortWithStatusJSON(code int, obj interface{})
 }
 
-const responseErr = "responseErr"
+const (
+	responseErr   = "responseErr"
+	responseStack = "responseStack"
+)
 
 type HTTPContext interface {
 	JSON(int, any)
@@ -49,108 +57,91 @@ func Success(c HTTPContext, bean any) {
 type WithData func(map[string]any)
 
 // Fail 通用错误返回
+// 未包装的记录不存在、数据重复、超时错误，分别转换为 ErrNotFound、ErrConflict、ErrTimeout
 func Fail(c ResponseWriter, err error, fn ...WithData) {
+	writeError(c, err, false, fn)
+}
+
+func AbortWithStatusJSON(c ResponseWriter, err error, fn ...WithData) {
+	writeError(c, err, true, fn)
+}
+
+func writeError(c ResponseWriter, err error, abort bool, fn []WithData) {
 	out := make(map[string]any)
 	if traceID, ok := TraceID(c); ok {
 		out["trace_id"] = traceID
 	}
 
 	code := 400
+	err = translateError(err)
 
-	if err1, ok := err.(Errorer); ok {
-
-		if ok {
-			code = err1.HTTPCode()
-			out["reason"] = err1.Reason()
-			out["msg"] = err1.Message()
-			d := err1.Details()
-			if defaultDebug && len(d) > 0 {
-				out["details"] = d
-			}
+	var err1 Errorer
+	if errors.As(err, &err1) {
+		code = err1.HTTPCode()
+		out["reason"] = err1.Reason()
+		out["msg"] = localizedMessage(c, err1)
+		d := err1.Details()
+		if defaultDebug && len(d) > 0 {
+			out["details"] = d
+		}
+		if v, ok := err1.(interface{ Fields() []FieldError }); ok && len(v.Fields()) > 0 {
+			out["fields"] = v.Fields()
 		}
 		for i := range fn {
 			fn[i](out)
 		}
-		c.JSON(code, out)
-		c.Set(responseErr, err.Error())
-		return
 	}
 
-	// if err, ok := err.(*errors.Error); ok {
-	// 	out["reason"] = err.Reason
-	// 	out["msg"] = err.Message
-	// 	d := err.Metadata
-	// 	if defaultDebug && len(d) > 0 {
-	// 		details := make([]string, 0, 3)
-	// 		for k, v := range d {
-	// 			details = append(details, fmt.Sprintf("%s:%v", k, v))
-	// 		}
-	// 		out["details"] = details
-	// 	}
-	// 	for i := range fn {
-	// 		fn[i](out)
-	// 	}
-	// 	c.JSON(code, out)
-	// 	c.Set(responseErr, err.Error())
-	// 	return
-	// }
-
-	// if errors.Is(err, context.DeadlineExceeded) {
-	// 	out["reason"] = "TIMEOUT"
-	// 	out["msg"] = "请求超时"
-	// 	c.JSON(code, out)
-	// 	c.Set(responseErr, err.Error())
-	// 	return
-	// }
-
-	c.JSON(code, out)
-	c.Set(responseErr, err.Error())
+	if useProblem(c) {
+		writeProblem(c, code, out, abort)
+	} else if abort {
+		c.AbortWithStatusJSON(code, out)
+	} else {
+		c.JSON(code, out)
+	}
+	setResponseErr(c, err)
 }
 
-func AbortWithStatusJSON(c ResponseWriter, err error, fn ...WithData) {
-	out := make(map[string]any)
-
-	err1, ok := err.(Errorer)
-
-	var code int
-	if ok {
-		code = err1.HTTPCode()
-		out["reason"] = err1.Reason()
-		out["msg"] = err1.Message()
-		d := err1.Details()
-		if defaultDebug && len(d) > 0 {
-			out["details"] = d
-		}
+// translateError 将常见的原始错误转换为 *Error，已包含 *Error 时不处理
+func translateError(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
 	}
-	if traceID, ok := TraceID(c); ok {
-		out["trace_id"] = traceID
-	}
-	for i := range fn {
-		fn[i](out)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound.wrap(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrConflict.wrap(err)
+	case errors.Is(err, orm.ErrInvalidQuery):
+		return ErrBadRequest.wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout.wrap(err)
 	}
-	c.AbortWithStatusJSON(code, out)
+	return err
+}
+
+// setResponseErr 记录错误，由 Logger 输出
+func setResponseErr(c ResponseWriter, err error) {
 	c.Set(responseErr, err.Error())
+	var e *Error
+	if errors.As(err, &e) {
+		if stack := e.Stack(); stack != "" {
+			c.Set(responseStack, stack)
+		}
+	}
 }
 
 // WarpH 让函数更专注于业务，一般入参和出参应该是指针类型
 // 没有入参时，应该使用 struct{}
+// 入参依次绑定 header < query < body < uri，后者覆盖前者，全部绑定后统一校验，见 Bind
 func WarpH[I any, O any](fn func(*gin.Context, *I) (O, error)) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var in I
 		if unsafe.Sizeof(in) != 0 {
-			switch c.Request.Method {
-			case http.MethodGet:
-				if err := c.ShouldBindQuery(&in); err != nil {
-					Fail(c, ErrBadRequest.With(HanddleJSONErr(err).Error()))
-					return
-				}
-			case http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch:
-				if c.Request.ContentLength > 0 {
-					if err := c.ShouldBindJSON(&in); err != nil {
-						Fail(c, ErrBadRequest.With(HanddleJSONErr(err).Error()))
-						return
-					}
-				}
+			if err := Bind(c, &in); err != nil {
+				Fail(c, err)
+				return
 			}
 		}
 		out, err := fn(c, &in)
@@ -166,14 +157,56 @@ type ResponseMsg struct {
 	Msg string `json:"msg"`
 }
 
-// HandlerResponseMsg 获取响应的结果
+// HandlerResponseMsg 获取响应的结果，非 2xx 时返回 DecodeError 的结果
 func HandlerResponseMsg(resp http.Response) error {
-	if resp.StatusCode == 200 {
+	return DecodeError(&resp)
+}
+
+// DecodeError 将非 2xx 响应转换为 *Error，2xx 时返回 nil
+// 支持 Fail 输出的 json 与 problem+json，其它格式按 http status code 转换为常用错误
+func DecodeError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		return nil
 	}
-	var out ResponseMsg
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return ErrServer.Msg(out.Msg)
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var out struct {
+		E
+		Detail string `json:"detail"` // problem+json
+	}
+	if err := json.Unmarshal(b, &out); err == nil && out.Reason != "" {
+		if out.Msg == "" {
+			out.Msg = out.Detail
+		}
+		return out.Err(resp.StatusCode)
+	}
+
+	base := errorByStatus(resp.StatusCode)
+	msg := strings.TrimSpace(string(b))
+	if msg == "" || len(msg) > 256 {
+		msg = resp.Status
+	}
+	return E{Reason: base.Reason(), Msg: msg}.Err(resp.StatusCode)
+}
+
+func errorByStatus(code int) *Error {
+	switch code {
+	case http.StatusUnauthorized:
+		return ErrUnauthorizedToken
+	case http.StatusForbidden:
+		return ErrPermissionDenied
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusRequestEntityTooLarge:
+		return ErrRequestTooLarge
+	case http.StatusTooManyRequests:
+		return ErrTooManyRequests
+	case http.StatusGatewayTimeout:
+		return ErrTimeout
+	}
+	if code >= 500 {
+		return ErrServer
 	}
-	return ErrServer.Msg(resp.Status)
+	return ErrBadRequest
 }
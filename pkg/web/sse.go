@@ -2,12 +2,12 @@ package web
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -40,15 +40,18 @@ type SSE struct {
 	Headers map[string]string
 	stream  chan Event
 	timeout time.Duration
-	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
 }
 
 type Event struct {
 	ID    string
 	Event string
 	Data  []byte
+	Topic string // 由 Hub 填充
 }
 
+// NewSSE timeout 为单次写入超时
 func NewSSE(length int, timeout time.Duration) *SSE {
 	if length <= 0 {
 		length = 1024
@@ -56,24 +59,30 @@ func NewSSE(length int, timeout time.Duration) *SSE {
 	return &SSE{
 		stream:  make(chan Event, length),
 		timeout: timeout,
+		done:    make(chan struct{}),
 	}
 }
 
+// Publish 缓冲满时阻塞，Close 之后调用会被忽略
 func (s *SSE) Publish(v Event) {
-	s.stream <- v
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	select {
+	case <-s.done:
+	case s.stream <- v:
+	}
 }
 
+// Close 发送完已缓冲的事件后结束 ServeHTTP，可重复调用
 func (s *SSE) Close() {
-	cancel := s.cancel
-	if cancel != nil {
-		s.cancel()
-	}
-	close(s.stream)
+	s.once.Do(func() { close(s.done) })
 }
 
 func (s *SSE) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	rc := http.NewResponseController(w) // nolint
-	_ = rc.SetWriteDeadline(time.Now().Add(s.timeout))
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -83,26 +92,39 @@ func (s *SSE) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set(k, v)
 	}
 
-	ctx, cancel := context.WithCancel(req.Context())
-	s.cancel = cancel
+	write := func(ev Event) bool {
+		if len(ev.Data) == 0 {
+			return true
+		}
+		if s.timeout > 0 {
+			_ = rc.SetWriteDeadline(time.Now().Add(s.timeout))
+		}
+		if _, err := io.WriteString(w, formatEvent(ev)); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
 
+	ctx := req.Context()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case ev := <-s.stream:
-			if len(ev.Data) == 0 {
-				continue
+			if !write(ev) {
+				return
 			}
-			if len(ev.ID) > 0 {
-				fmt.Fprintf(w, "id: %s\n", ev.ID)
-			}
-			if len(ev.Event) > 0 {
-				fmt.Fprintf(w, "event: %s\n", ev.Event)
+		case <-s.done:
+			for {
+				select {
+				case ev := <-s.stream:
+					if !write(ev) {
+						return
+					}
+				default:
+					return
+				}
 			}
-			fmt.Fprintf(w, "data: %s\n", ev.Data)
-			fmt.Fprint(w, "\n")
-			rc.Flush()
 		}
 	}
 }
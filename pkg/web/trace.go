@@ -0,0 +1,359 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpanKind 与 OpenTelemetry 的 SpanKind 取值一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// span 状态码，与 OpenTelemetry 的 StatusCode 取值一致
+const (
+	statusUnset = 0
+	statusOK    = 1
+	statusError = 2
+)
+
+const (
+	ctxSpanKey   ctxKey = "SPAN_KEY"
+	ctxRemoteKey ctxKey = "REMOTE_SPAN_KEY"
+)
+
+// SpanContext 跨进程传递的 span 信息
+type SpanContext struct {
+	TraceID string // 32 位十六进制
+	SpanID  string // 16 位十六进制
+	Sampled bool
+}
+
+// TraceParent 格式化为 W3C traceparent
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID + "-" + sc.SpanID + "-" + flags
+}
+
+// ParseTraceParent 解析 W3C traceparent，格式为 version-traceid-parentid-flags
+func ParseTraceParent(v string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	for _, p := range parts[:4] {
+		if !isLowerHex(p) {
+			return SpanContext{}, false
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return SpanContext{}, false
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return SpanContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags[0]&1 == 1}, true
+}
+
+func isLowerHex(s string) bool {
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithSpanContext 写入远端 span，作为后续 span 的父节点
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, ctxRemoteKey, sc)
+}
+
+// SpanContextFromContext 获取当前 span，没有时获取远端 span
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	ctx = requestContext(ctx)
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc, true
+	}
+	sc, ok := ctx.Value(ctxRemoteKey).(SpanContext)
+	return sc, ok
+}
+
+// SpanFromContext 获取当前 span，没有时返回 nil，nil span 的方法均可安全调用
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := requestContext(ctx).Value(ctxSpanKey).(*Span)
+	return s
+}
+
+// InjectTraceParent 向下游请求写入 traceparent
+func InjectTraceParent(ctx context.Context, h http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		h.Set(HeaderTraceParent, sc.TraceParent())
+	}
+}
+
+// requestContext gin.Context 仅支持 string 类型的 key，使用 request context
+func requestContext(ctx context.Context) context.Context {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		return c.Request.Context()
+	}
+	return ctx
+}
+
+// Span 一次调用
+type Span struct {
+	tracer   *Tracer
+	sc       SpanContext
+	parentID string
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	m          sync.Mutex
+	end        time.Time
+	attributes []attribute
+	status     int
+	statusMsg  string
+	ended      bool
+}
+
+type attribute struct {
+	key   string
+	value any
+}
+
+// SpanContext ...
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes 按 key value 成对设置属性
+func (s *Span) SetAttributes(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		s.attributes = append(s.attributes, attribute{key: fmt.Sprint(kv[i]), value: kv[i+1]})
+	}
+}
+
+// SetError 标记为失败，err 为 nil 时忽略
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.status = statusError
+	s.statusMsg = err.Error()
+}
+
+// End 结束 span，重复调用无效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.m.Lock()
+	if s.ended {
+		s.m.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.m.Unlock()
+	if s.sc.Sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// Tracer 创建 span，并批量导出
+// nil Tracer 不记录 span，方法均可安全调用
+type Tracer struct {
+	service  string
+	exporter SpanExporter
+	spans    chan *Span
+	batch    int
+	interval time.Duration
+
+	quit     chan struct{}
+	done     chan struct{}
+	shutdown sync.Once
+}
+
+// NewTracer service 为服务名，写入 resource 的 service.name
+func NewTracer(service string, exporter SpanExporter) *Tracer {
+	t := Tracer{
+		service:  service,
+		exporter: exporter,
+		spans:    make(chan *Span, 2048),
+		batch:    256,
+		interval: 5 * time.Second,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return &t
+}
+
+// Start 创建 span，父节点为 ctx 中的 span 或远端 span
+// ctx 为 *gin.Context 时，返回基于 c.Request.Context() 的 context
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	ctx = requestContext(ctx)
+	if t == nil {
+		return ctx, nil
+	}
+	s := Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		sc:     SpanContext{SpanID: newSpanID(), Sampled: true},
+	}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parentID = parent.SpanID
+	} else {
+		s.sc.TraceID = NewTraceID()
+	}
+	return context.WithValue(ctx, ctxSpanKey, &s), &s
+}
+
+// ConcSpan 适配 conc.SpanFunc，为 conc.G 的任务创建 span
+func (t *Tracer) ConcSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	if t == nil || SpanFromContext(ctx) == nil {
+		return ctx, func(error) {}
+	}
+	ctx, span := t.Start(ctx, name, SpanKindInternal)
+	return ctx, func(err error) {
+		span.SetError(err)
+		span.End()
+	}
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case t.spans <- s:
+	default:
+		// 队列已满时丢弃，避免阻塞业务
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	buf := make([]*Span, 0, t.batch)
+	flush := func() {
+		if len(buf) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := t.exporter.Export(ctx, newTracesData(t.service, buf)); err != nil {
+			slog.Error("trace export", "err", err, "spans", len(buf))
+		}
+		buf = buf[:0]
+	}
+	for {
+		select {
+		case s := <-t.spans:
+			buf = append(buf, s)
+			if len(buf) >= t.batch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.quit:
+			for {
+				select {
+				case s := <-t.spans:
+					buf = append(buf, s)
+					if len(buf) >= t.batch {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Shutdown 导出剩余的 span
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.shutdown.Do(func() { close(t.quit) })
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newSpanID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Tracing 为每个请求创建 server span，应在 Logger 之前使用，使日志与 span 的 trace id 一致
+// tracer 为 nil 时仅透传
+func Tracing(t *Tracer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if t == nil {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		if sc, ok := ParseTraceParent(c.GetHeader(HeaderTraceParent)); ok {
+			ctx = ContextWithSpanContext(ctx, sc)
+		}
+		name := c.FullPath()
+		if name == "" {
+			name = "NotFound"
+		}
+		ctx, span := t.Start(ctx, c.Request.Method+" "+name, SpanKindServer)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		SetTraceID(c, span.sc.TraceID)
+
+		c.Next()
+
+		code := c.Writer.Status()
+		span.SetAttributes(
+			"http.request.method", c.Request.Method,
+			"http.route", c.FullPath(),
+			"url.path", c.Request.URL.Path,
+			"client.address", c.ClientIP(),
+			"http.response.status_code", code,
+		)
+		if code >= 500 {
+			span.SetError(fmt.Errorf("%d %s", code, http.StatusText(code)))
+		}
+	}
+}
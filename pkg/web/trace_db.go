@@ -0,0 +1,82 @@
+package web
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const traceSpanKey = "goweb:trace_span"
+
+// DBTracePlugin 为 sql 创建 span，需要调用 db.WithContext(ctx)
+// 仅在 ctx 中已有 span 时创建，避免后台任务产生大量无关的 trace
+type DBTracePlugin struct {
+	tracer *Tracer
+}
+
+var _ gorm.Plugin = (*DBTracePlugin)(nil)
+
+// NewDBTracePlugin 使用 db.Use(web.NewDBTracePlugin(tracer)) 注册
+func NewDBTracePlugin(tracer *Tracer) *DBTracePlugin {
+	return &DBTracePlugin{tracer: tracer}
+}
+
+// Name implements gorm.Plugin.
+func (*DBTracePlugin) Name() string {
+	return "goweb:trace"
+}
+
+// Initialize implements gorm.Plugin.
+func (p *DBTracePlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("goweb:trace_before_create", p.before("create")),
+		cb.Create().After("gorm:create").Register("goweb:trace_after_create", p.after),
+		cb.Query().Before("gorm:query").Register("goweb:trace_before_query", p.before("query")),
+		cb.Query().After("gorm:query").Register("goweb:trace_after_query", p.after),
+		cb.Update().Before("gorm:update").Register("goweb:trace_before_update", p.before("update")),
+		cb.Update().After("gorm:update").Register("goweb:trace_after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("goweb:trace_before_delete", p.before("delete")),
+		cb.Delete().After("gorm:delete").Register("goweb:trace_after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("goweb:trace_before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("goweb:trace_after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("goweb:trace_before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("goweb:trace_after_raw", p.after),
+	}
+	return errors.Join(errs...)
+}
+
+func (p *DBTracePlugin) before(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || SpanFromContext(ctx) == nil {
+			return
+		}
+		name := "gorm." + op
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		_, span := p.tracer.Start(ctx, name, SpanKindClient)
+		db.InstanceSet(traceSpanKey, span)
+	}
+}
+
+func (p *DBTracePlugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(traceSpanKey)
+	if !ok {
+		return
+	}
+	span, _ := v.(*Span)
+	span.SetAttributes(
+		"db.system", db.Dialector.Name(),
+		"db.statement", db.Statement.SQL.String(),
+		"db.rows_affected", db.Statement.RowsAffected,
+	)
+	if db.Statement.Table != "" {
+		span.SetAttributes("db.sql.table", db.Statement.Table)
+	}
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.SetError(err)
+	}
+	span.End()
+}
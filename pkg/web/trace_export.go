@@ -0,0 +1,192 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SpanExporter 导出 span
+type SpanExporter interface {
+	Export(ctx context.Context, data *TracesData) error
+}
+
+// TracesData OTLP/JSON 格式，参考 opentelemetry-proto 的 ExportTraceServiceRequest
+// trace id 与 span id 使用十六进制，时间使用字符串格式的纳秒
+type TracesData struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeSpans struct {
+	Scope Scope      `json:"scope"`
+	Spans []SpanData `json:"spans"`
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+type SpanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Status            SpanStatus `json:"status"`
+}
+
+type SpanStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func newAnyValue(v any) AnyValue {
+	switch v := v.(type) {
+	case string:
+		return AnyValue{StringValue: &v}
+	case bool:
+		return AnyValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+		return AnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return AnyValue{IntValue: &s}
+	case float64:
+		return AnyValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return AnyValue{StringValue: &s}
+	}
+}
+
+func newTracesData(service string, spans []*Span) *TracesData {
+	out := make([]SpanData, 0, len(spans))
+	for _, s := range spans {
+		s.m.Lock()
+		d := SpanData{
+			TraceID:           s.sc.TraceID,
+			SpanID:            s.sc.SpanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            SpanStatus{Code: s.status, Message: s.statusMsg},
+		}
+		for _, a := range s.attributes {
+			d.Attributes = append(d.Attributes, KeyValue{Key: a.key, Value: newAnyValue(a.value)})
+		}
+		s.m.Unlock()
+		out = append(out, d)
+	}
+	return &TracesData{ResourceSpans: []ResourceSpans{{
+		Resource:   Resource{Attributes: []KeyValue{{Key: "service.name", Value: newAnyValue(service)}}},
+		ScopeSpans: []ScopeSpans{{Scope: Scope{Name: "github.com/ixugo/goweb/pkg/web"}, Spans: out}},
+	}}}
+}
+
+// OTLPExporter 通过 OTLP/HTTP JSON 导出，endpoint 例如 http://127.0.0.1:4318/v1/traces
+type OTLPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+// NewOTLPExporter ...
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		Endpoint: endpoint,
+		Headers:  headers,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export implements SpanExporter.
+func (e *OTLPExporter) Export(ctx context.Context, data *TracesData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp export: %s", resp.Status)
+	}
+	return nil
+}
+
+// FileExporter 导出到本地文件，每批 span 一行 OTLP/JSON
+type FileExporter struct {
+	m sync.Mutex
+	w io.Writer
+}
+
+// NewFileExporter 追加写入文件
+func NewFileExporter(path string) (*FileExporter, func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &FileExporter{w: f}, f.Close, nil
+}
+
+// NewWriterExporter ...
+func NewWriterExporter(w io.Writer) *FileExporter {
+	return &FileExporter{w: w}
+}
+
+// Export implements SpanExporter.
+func (e *FileExporter) Export(_ context.Context, data *TracesData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	e.m.Lock()
+	defer e.m.Unlock()
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/ixugo/goweb/pkg/conc"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	sc, ok := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID)
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID)
+	require.True(t, sc.Sampled)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.TraceParent())
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceParent(v)
+		require.False(t, ok, v)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	var m sync.Mutex
+	var spans []SpanData
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data TracesData
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&data))
+		require.Equal(t, "test", *data.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+		m.Lock()
+		spans = append(spans, data.ResourceSpans[0].ScopeSpans[0].Spans...)
+		m.Unlock()
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer("test", NewOTLPExporter(collector.URL, nil))
+	g := conc.New(nil).WithSpan(tracer.ConcSpan)
+
+	r := gin.New()
+	r.Use(Tracing(tracer), Logger(L(context.Background()), func(*gin.Context) bool { return false }))
+	r.GET("/users/:id", func(c *gin.Context) {
+		g.GoRunContext(c, "task", func(ctx context.Context) {
+			SpanFromContext(ctx).SetError(errors.New("failed"))
+		})
+		g.Wait()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(HeaderTraceParent, parent)
+	r.ServeHTTP(w, req)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", w.Header().Get(HeaderRequestID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	require.NoError(t, tracer.Shutdown(ctx))
+
+	require.Len(t, spans, 2)
+	task, server := spans[0], spans[1]
+	require.Equal(t, "GET /users/:id", server.Name)
+	require.Equal(t, SpanKindServer, server.Kind)
+	require.Equal(t, "00f067aa0ba902b7", server.ParentSpanID)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID)
+	require.Equal(t, statusError, server.Status.Code)
+
+	require.Equal(t, "task", task.Name)
+	require.Equal(t, server.TraceID, task.TraceID)
+	require.Equal(t, server.SpanID, task.ParentSpanID)
+	require.Equal(t, "failed", task.Status.Message)
+}
+
+func TestTracerNil(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "noop", SpanKindInternal)
+	span.SetAttributes("k", "v")
+	span.End()
+	require.Nil(t, SpanFromContext(ctx))
+	require.NoError(t, tracer.Shutdown(ctx))
+}
+
+func TestDBTracePlugin(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer("test", NewWriterExporter(&buf))
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.Use(NewDBTracePlugin(tracer)))
+
+	type User struct {
+		ID   int
+		Name string
+	}
+	require.NoError(t, db.AutoMigrate(new(User)))
+	// 没有父 span 时不记录
+	require.NoError(t, db.Create(&User{Name: "a"}).Error)
+
+	ctx, span := tracer.Start(context.Background(), "request", SpanKindServer)
+	var u User
+	require.NoError(t, db.WithContext(ctx).First(&u).Error)
+	span.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	var data TracesData
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &data))
+	spans := data.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	require.Equal(t, "gorm.query users", spans[0].Name)
+	require.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	require.Contains(t, *spans[0].Attributes[1].Value.StringValue, "SELECT")
+}
@@ -1,8 +1,12 @@
 package web
 
+import "slices"
+
 // Validator 验证对象是否合法
+// 按添加顺序输出错误，同一字段只记录第一个错误
 type Validator struct {
 	Errors map[string]string
+	fields []FieldError
 }
 
 // NewValidator ...
@@ -17,8 +21,14 @@ func (v *Validator) Valid() bool {
 
 // AddError 添加错误
 func (v *Validator) AddError(key, message string) *Validator {
+	return v.AddFieldError(key, "", message)
+}
+
+// AddFieldError 添加错误，rule 为违反的规则，如 required
+func (v *Validator) AddFieldError(key, rule, message string) *Validator {
 	if _, exist := v.Errors[key]; !exist {
 		v.Errors[key] = message
+		v.fields = append(v.fields, FieldError{Field: key, Rule: rule, Msg: message})
 	}
 	return v
 }
@@ -38,9 +48,37 @@ func (v *Validator) Result() (bool, []string) {
 
 // List 验证 !Valid() 后，可获取错误列表
 func (v *Validator) List() []string {
-	tmp := make([]string, 0, len(v.Errors))
-	for k, v := range v.Errors {
-		tmp = append(tmp, k+" "+v)
+	fields := v.Fields()
+	tmp := make([]string, 0, len(fields))
+	for _, f := range fields {
+		tmp = append(tmp, f.Field+" "+f.Msg)
 	}
 	return tmp
 }
+
+// Fields 字段错误列表，直接写入 Errors 的错误按 key 排序追加在后面
+func (v *Validator) Fields() []FieldError {
+	if len(v.fields) == len(v.Errors) {
+		return v.fields
+	}
+	out := slices.Clone(v.fields)
+	keys := make([]string, 0, len(v.Errors))
+	for k := range v.Errors {
+		if !slices.ContainsFunc(v.fields, func(f FieldError) bool { return f.Field == k }) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		out = append(out, FieldError{Field: k, Msg: v.Errors[k]})
+	}
+	return out
+}
+
+// Err 没有错误时返回 nil，否则返回包含字段错误的 ErrBadRequest
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	return ErrBadRequest.WithFields(v.Fields()...)
+}
@@ -1,10 +1,14 @@
 package web
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/ixugo/goweb/pkg/orm"
+	"gorm.io/gorm"
 )
 
 // ScrollPager 滚动翻页
@@ -13,6 +17,17 @@ type ScrollPager[T any] struct {
 	Next  string `json:"next"`
 }
 
+// FindScroll 滚动翻页，返回 ScrollPager，见 orm.FindScroll
+//
+//	return web.FindScroll[User](c.Request.Context(), db, in.PagerFilter, opts...)
+func FindScroll[T any](ctx context.Context, db *gorm.DB, p PagerFilter, opts ...orm.QueryOption) (ScrollPager[*T], error) {
+	items, next, err := orm.FindScroll[T](ctx, db, p, opts...)
+	if err != nil {
+		return ScrollPager[*T]{Items: make([]*T, 0)}, err
+	}
+	return ScrollPager[*T]{Items: items, Next: next}, nil
+}
+
 // PageOutput 分页数据
 type PageOutput struct {
 	Total int64 `json:"total"`
@@ -21,10 +36,18 @@ type PageOutput struct {
 
 // PagerFilter 分页过滤
 type PagerFilter struct {
-	Page         int      `form:"page"`
-	Size         int      `form:"size"`
-	Sort         string   `form:"sort"`
-	SortSafelist []string `json:"-"`
+	Page         int               `form:"page"`
+	Size         int               `form:"size"`
+	Sort         string            `form:"sort"`            // 多个字段使用逗号分隔，如 -created_at,name
+	Next         string            `form:"next"`            // 滚动翻页的游标，来自上一页的 ScrollPager.Next
+	Filter       map[string]string `form:"filter" json:"-"` // 过滤条件，如 filter[status]=in:1,2
+	SortSafelist []string          `json:"-"`
+}
+
+// SortField 排序字段
+type SortField struct {
+	Column string
+	Desc   bool
 }
 
 func NewPagerFilterMaxSize() PagerFilter {
@@ -80,6 +103,20 @@ func (f PagerFilter) SortColumn() (string, error) {
 	return "", fmt.Errorf("%s 不支持排序", f.Sort)
 }
 
+// SortFields 解析多字段排序，不校验字段
+func (f PagerFilter) SortFields() []SortField {
+	var out []SortField
+	for _, v := range strings.Split(f.Sort, ",") {
+		v = strings.TrimSpace(v)
+		col := strings.TrimLeft(v, "-+")
+		if col == "" {
+			continue
+		}
+		out = append(out, SortField{Column: col, Desc: strings.HasPrefix(v, "-")})
+	}
+	return out
+}
+
 // SortDirection 如果 sort 携带负号返回倒序，否则返回正序
 func (f PagerFilter) SortDirection() string {
 	if strings.HasPrefix(f.Sort, "-") {
@@ -88,6 +125,21 @@ func (f PagerFilter) SortDirection() string {
 	return "ASC"
 }
 
+// Cursor 滚动翻页的游标
+func (f PagerFilter) Cursor() string {
+	return f.Next
+}
+
+// Filters 过滤条件，用于 orm.QuerySpec
+func (f PagerFilter) Filters() map[string]string {
+	return f.Filter
+}
+
+// SortBy 排序参数，用于 orm.QuerySpec
+func (f PagerFilter) SortBy() string {
+	return f.Sort
+}
+
 // Offset 计算偏离数值
 func (f PagerFilter) Offset() int {
 	if f.Page < 1 {
@@ -1 +1,41 @@
 package web
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestFindScroll(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	require.NoError(t, err)
+	type item struct {
+		ID int
+	}
+	require.NoError(t, db.AutoMigrate(new(item)))
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.Create(&item{ID: i}).Error)
+	}
+	ctx := context.Background()
+
+	p := PagerFilter{Size: 2}
+	out, err := FindScroll[item](ctx, db, p)
+	require.NoError(t, err)
+	require.Len(t, out.Items, 2)
+	require.NotEmpty(t, out.Next)
+
+	p.Next = out.Next
+	out, err = FindScroll[item](ctx, db, p)
+	require.NoError(t, err)
+	require.Len(t, out.Items, 1)
+	require.Equal(t, 3, out.Items[0].ID)
+	require.Empty(t, out.Next)
+
+	p.Next = "invalid"
+	_, err = FindScroll[item](ctx, db, p)
+	require.ErrorIs(t, translateError(err), ErrBadRequest)
+}
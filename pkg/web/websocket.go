@@ -0,0 +1,551 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" // nolint
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RFC 6455 帧类型
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// RFC 6455 关闭码
+const (
+	WSCloseNormal          = 1000
+	WSCloseGoingAway       = 1001
+	WSCloseProtocolError   = 1002
+	WSCloseUnsupportedData = 1003
+	WSCloseInvalidPayload  = 1007
+	WSClosePolicyViolation = 1008
+	WSCloseTooLarge        = 1009
+)
+
+// 客户端消息类型，其它类型交给 WebSocketConfig.OnMessage 处理
+const (
+	WSTypeEvent       = "event"       // 服务端推送的 Hub 事件
+	WSTypeError       = "error"       // 服务端返回的错误，data 为 E
+	WSTypeSubscribe   = "subscribe"   // 客户端订阅 topic，id 为 Last-Event-ID
+	WSTypeUnsubscribe = "unsubscribe" // 客户端取消订阅 topic
+	WSTypePing        = "ping"        // 浏览器无法发送 ping 帧，使用消息保活
+	WSTypePong        = "pong"
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrWSClosed 连接已关闭
+var ErrWSClosed = errors.New("websocket closed")
+
+// WSMessage 消息信封，收发均为 json 文本帧
+type WSMessage struct {
+	Type  string          `json:"type"`
+	Topic string          `json:"topic,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Event string          `json:"event,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// WebSocketConfig 零值字段使用默认值
+type WebSocketConfig struct {
+	Hub *Hub // 为空时不推送事件
+	// Topics 连接建立时订阅的主题
+	Topics func(c *gin.Context) []string
+	// Authorize 校验客户端订阅的主题，为空时不允许客户端订阅
+	Authorize func(c *gin.Context, topic string) bool
+	// OnMessage 处理业务消息，在读协程中调用
+	OnMessage func(conn *WSConn, msg WSMessage)
+	// CheckOrigin 为空时仅允许同源或未携带 Origin 的请求
+	CheckOrigin func(r *http.Request) bool
+	// Revoke 不为空时，每次发送 ping 前检查令牌是否已吊销
+	Revoke RevokeStorer
+
+	PingInterval   time.Duration // 默认 30s
+	PongTimeout    time.Duration // 超过该时间未收到任何帧则断开，默认 60s
+	WriteTimeout   time.Duration // 单次写入超时，默认 10s
+	MaxMessageSize int64         // 默认 64KB
+	Buffer         int           // 发送缓冲，写满视为慢消费者并断开，默认 64
+}
+
+// WebSocket 升级为 websocket 连接，需放在 AuthMiddleware 之后，未认证时返回 401
+// 令牌过期或被吊销后以 WSClosePolicyViolation 断开
+/*
+	使用案例
+
+	hub := web.NewHub(web.HubConfig{})
+	auth := r.Group("", web.AuthMiddleware(secret))
+	auth.GET("/events", func(c *gin.Context) {
+		hub.ServeSSE(c.Writer, c.Request, "orders")
+	})
+	auth.GET("/ws", web.WebSocket(web.WebSocketConfig{
+		Hub:    hub,
+		Topics: func(c *gin.Context) []string { return []string{"orders"} },
+	}))
+	hub.Publish("orders", web.Event{Event: "created", Data: b}) // 同时推送给 SSE 与 websocket
+*/
+func WebSocket(cfg WebSocketConfig) gin.HandlerFunc {
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = 30 * time.Second
+	}
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = 60 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 10 * time.Second
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = 64 << 10
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 64
+	}
+	if cfg.CheckOrigin == nil {
+		cfg.CheckOrigin = sameOrigin
+	}
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			AbortWithStatusJSON(c, ErrUnauthorizedToken.With("未鉴权"))
+			return
+		}
+		if !cfg.CheckOrigin(c.Request) {
+			AbortWithStatusJSON(c, ErrPermissionDenied.With("origin 不允许"))
+			return
+		}
+		key, err := checkHandshake(c.Request)
+		if err != nil {
+			c.Header("Sec-WebSocket-Version", "13")
+			AbortWithStatusJSON(c, ErrBadRequest.With(err.Error()))
+			return
+		}
+		netConn, brw, err := http.NewResponseController(c.Writer).Hijack() // nolint
+		if err != nil {
+			AbortWithStatusJSON(c, ErrServer.With(err.Error()))
+			return
+		}
+		c.Abort()
+		_ = netConn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+		if _, err := io.WriteString(netConn, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: "+wsAccept(key)+"\r\n\r\n"); err != nil {
+			netConn.Close()
+			return
+		}
+
+		var topics []string
+		if cfg.Topics != nil {
+			topics = cfg.Topics(c)
+		}
+		conn := newWSConn(c, &cfg, netConn, brw.Reader, claims)
+		if cfg.Hub != nil {
+			conn.sub = cfg.Hub.Subscribe(c.GetHeader("Last-Event-ID"), topics...)
+			defer conn.sub.Close()
+		}
+		go conn.writePump()
+		conn.readLoop()
+		<-conn.writeDone
+	}
+}
+
+// WSConn websocket 连接
+type WSConn struct {
+	gc        *gin.Context
+	cfg       *WebSocketConfig
+	conn      net.Conn
+	r         *bufio.Reader
+	claims    *Claims
+	sub       *Subscription
+	ctx       context.Context
+	cancel    context.CancelFunc
+	send      chan []byte
+	pong      chan []byte
+	closeCh   chan []byte
+	closeOnce sync.Once
+	writeDone chan struct{}
+}
+
+func newWSConn(c *gin.Context, cfg *WebSocketConfig, conn net.Conn, r *bufio.Reader, claims *Claims) *WSConn {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	return &WSConn{
+		gc:        c,
+		cfg:       cfg,
+		conn:      conn,
+		r:         r,
+		claims:    claims,
+		ctx:       ctx,
+		cancel:    cancel,
+		send:      make(chan []byte, cfg.Buffer),
+		pong:      make(chan []byte, 1),
+		closeCh:   make(chan []byte, 1),
+		writeDone: make(chan struct{}),
+	}
+}
+
+// Context 连接断开后取消
+func (c *WSConn) Context() context.Context {
+	return c.ctx
+}
+
+// Claims 建立连接时的令牌内容
+func (c *WSConn) Claims() *Claims {
+	return c.claims
+}
+
+// Send 发送消息，发送缓冲已满时断开连接
+func (c *WSConn) Send(msg WSMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.ctx.Done():
+		return ErrWSClosed
+	default:
+	}
+	select {
+	case c.send <- b:
+		return nil
+	default:
+		c.Close(WSClosePolicyViolation, "slow consumer")
+		return ErrWSClosed
+	}
+}
+
+// SendError 发送错误消息
+func (c *WSConn) SendError(err error) error {
+	e := E{Reason: ErrUnknown.Reason(), Msg: err.Error()}
+	var err1 Errorer
+	if errors.As(translateError(err), &err1) {
+		e.Reason = err1.Reason()
+		e.Msg = err1.Message()
+		if defaultDebug {
+			e.Details = err1.Details()
+		}
+	}
+	data, _ := json.Marshal(e)
+	return c.Send(WSMessage{Type: WSTypeError, Data: data})
+}
+
+// Close 发送关闭帧后断开连接，可重复调用
+func (c *WSConn) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		p := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(p, uint16(code))
+		c.closeCh <- append(p, reason...)
+	})
+}
+
+func (c *WSConn) readLoop() {
+	defer c.cancel()
+	var (
+		msg   []byte
+		msgOp byte
+		inMsg bool
+	)
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout))
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			var ce *wsCloseError
+			if errors.As(err, &ce) {
+				c.Close(ce.code, ce.reason)
+			} else {
+				c.Close(WSCloseGoingAway, "")
+			}
+			return
+		}
+		switch op {
+		case wsOpPing:
+			select {
+			case c.pong <- payload:
+			default:
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			code := WSCloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.Close(code, "")
+			return
+		case wsOpContinuation:
+			if !inMsg {
+				c.Close(WSCloseProtocolError, "unexpected continuation")
+				return
+			}
+		case wsOpText, wsOpBinary:
+			if inMsg {
+				c.Close(WSCloseProtocolError, "expected continuation")
+				return
+			}
+			inMsg, msgOp, msg = true, op, msg[:0]
+		default:
+			c.Close(WSCloseProtocolError, "unknown opcode")
+			return
+		}
+		if int64(len(msg)+len(payload)) > c.cfg.MaxMessageSize {
+			c.Close(WSCloseTooLarge, "message too large")
+			return
+		}
+		msg = append(msg, payload...)
+		if !fin {
+			continue
+		}
+		inMsg = false
+		if msgOp != wsOpText {
+			c.Close(WSCloseUnsupportedData, "text only")
+			return
+		}
+		if !utf8.Valid(msg) {
+			c.Close(WSCloseInvalidPayload, "invalid utf-8")
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *WSConn) handle(b []byte) {
+	var msg WSMessage
+	if err := json.Unmarshal(b, &msg); err != nil {
+		_ = c.SendError(ErrJSON.With(err.Error()))
+		return
+	}
+	switch msg.Type {
+	case WSTypePing:
+		_ = c.Send(WSMessage{Type: WSTypePong, ID: msg.ID})
+	case WSTypeSubscribe:
+		if c.sub == nil || c.cfg.Authorize == nil || msg.Topic == "" || !c.cfg.Authorize(c.gc, msg.Topic) {
+			_ = c.SendError(ErrPermissionDenied.Withf("不允许订阅 %s", msg.Topic))
+			return
+		}
+		c.sub.Join(msg.Topic, msg.ID)
+	case WSTypeUnsubscribe:
+		if c.sub != nil {
+			c.sub.Leave(msg.Topic)
+		}
+	default:
+		if c.cfg.OnMessage != nil {
+			c.cfg.OnMessage(c, msg)
+		}
+	}
+}
+
+func (c *WSConn) writePump() {
+	defer close(c.writeDone)
+	defer c.conn.Close()
+	defer c.cancel()
+
+	tick := time.NewTicker(c.cfg.PingInterval)
+	defer tick.Stop()
+	var events <-chan Event
+	if c.sub != nil {
+		events = c.sub.C
+	}
+	var expired <-chan time.Time
+	if exp := c.claims.ExpiresAt; exp != nil {
+		timer := time.NewTimer(time.Until(exp.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		var err error
+		select {
+		case p := <-c.closeCh:
+			_ = c.writeFrame(wsOpClose, p)
+			// 等待对端的关闭帧，超时直接断开
+			select {
+			case <-c.ctx.Done():
+			case <-time.After(time.Second):
+			}
+			return
+		case <-c.ctx.Done():
+			c.Close(WSCloseGoingAway, "")
+			_ = c.writeFrame(wsOpClose, <-c.closeCh)
+			return
+		case b := <-c.send:
+			err = c.writeFrame(wsOpText, b)
+		case p := <-c.pong:
+			err = c.writeFrame(wsOpPong, p)
+		case ev, ok := <-events:
+			if !ok {
+				// Hub 关闭或被判定为慢消费者
+				events = nil
+				c.Close(WSCloseGoingAway, "")
+				continue
+			}
+			err = c.writeFrame(wsOpText, eventMessage(ev))
+		case <-expired:
+			expired = nil
+			c.Close(WSClosePolicyViolation, "token expired")
+		case <-tick.C:
+			if c.cfg.Revoke != nil {
+				if err := checkRevoked(c.ctx, c.cfg.Revoke, c.claims); err != nil {
+					slog.Warn("websocket token revoked", "uid", c.claims.UID, "err", err)
+					c.Close(WSClosePolicyViolation, "token revoked")
+					continue
+				}
+			}
+			err = c.writeFrame(wsOpPing, nil)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// eventMessage 将 Hub 事件编码为消息，Data 不是 json 时作为字符串
+func eventMessage(ev Event) []byte {
+	data := json.RawMessage(ev.Data)
+	if !json.Valid(ev.Data) {
+		data, _ = json.Marshal(string(ev.Data))
+	}
+	b, _ := json.Marshal(WSMessage{Type: WSTypeEvent, Topic: ev.Topic, ID: ev.ID, Event: ev.Event, Data: data})
+	return b
+}
+
+// writeFrame 服务端发送的帧不加掩码
+func (c *WSConn) writeFrame(op byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | op
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+	bufs := net.Buffers{header, payload}
+	_, err := bufs.WriteTo(c.conn)
+	return err
+}
+
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+func (e *wsCloseError) Error() string {
+	return e.reason
+}
+
+// readFrame 读取一帧，客户端的帧必须带掩码
+func (c *WSConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.r, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return fin, op, nil, &wsCloseError{WSCloseProtocolError, "rsv bits set"}
+	}
+	if header[1]&0x80 == 0 {
+		return fin, op, nil, &wsCloseError{WSCloseProtocolError, "frame not masked"}
+	}
+	n := uint64(header[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.r, b[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.r, b[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if op >= wsOpClose && (!fin || n > 125) {
+		return fin, op, nil, &wsCloseError{WSCloseProtocolError, "invalid control frame"}
+	}
+	if n > uint64(c.cfg.MaxMessageSize) {
+		return fin, op, nil, &wsCloseError{WSCloseTooLarge, "message too large"}
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// checkHandshake 校验升级请求，返回 Sec-WebSocket-Key
+func checkHandshake(r *http.Request) (string, error) {
+	if r.Method != http.MethodGet {
+		return "", errors.New("websocket 须使用 GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return "", errors.New("缺少 Upgrade: websocket")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", errors.New("仅支持 Sec-WebSocket-Version: 13")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return "", errors.New("Sec-WebSocket-Key 不合法")
+	}
+	return key, nil
+}
+
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wsAccept(key string) string {
+	h := sha1.New() // nolint
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
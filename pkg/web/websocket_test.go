@@ -0,0 +1,234 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// wsTestClient 测试用客户端，发送的帧带掩码
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWS(t *testing.T, url string, header http.Header) (*wsTestClient, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/ws", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	require.NoError(t, req.Write(conn))
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	require.NoError(t, err)
+	return &wsTestClient{t: t, conn: conn, r: r}, resp
+}
+
+func (c *wsTestClient) writeFrame(fin bool, op byte, payload []byte) {
+	b := []byte{op, 0x80}
+	if fin {
+		b[0] |= 0x80
+	}
+	if len(payload) < 126 {
+		b[1] |= byte(len(payload))
+	} else if len(payload) <= 0xFFFF {
+		b[1] |= 126
+		b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	} else {
+		b[1] |= 127
+		b = binary.BigEndian.AppendUint64(b, uint64(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	b = append(b, mask...)
+	for i, v := range payload {
+		b = append(b, v^mask[i%4])
+	}
+	_, err := c.conn.Write(b)
+	require.NoError(c.t, err)
+}
+
+func (c *wsTestClient) readFrame() (byte, []byte) {
+	var h [2]byte
+	_, err := io.ReadFull(c.r, h[:])
+	require.NoError(c.t, err)
+	require.Zero(c.t, h[1]&0x80, "服务端的帧不能带掩码")
+	n := int(h[1] & 0x7F)
+	if n == 126 {
+		var b [2]byte
+		_, err = io.ReadFull(c.r, b[:])
+		require.NoError(c.t, err)
+		n = int(binary.BigEndian.Uint16(b[:]))
+	}
+	payload := make([]byte, n)
+	_, err = io.ReadFull(c.r, payload)
+	require.NoError(c.t, err)
+	return h[0] & 0x0F, payload
+}
+
+func (c *wsTestClient) send(msg WSMessage) {
+	b, _ := json.Marshal(msg)
+	c.writeFrame(true, wsOpText, b)
+}
+
+func (c *wsTestClient) recv() WSMessage {
+	for {
+		op, payload := c.readFrame()
+		if op == wsOpPing {
+			continue
+		}
+		require.Equal(c.t, byte(wsOpText), op, string(payload))
+		var msg WSMessage
+		require.NoError(c.t, json.Unmarshal(payload, &msg))
+		return msg
+	}
+}
+
+func (c *wsTestClient) expectClose(code int) {
+	for {
+		op, payload := c.readFrame()
+		if op != wsOpClose {
+			continue
+		}
+		require.Equal(c.t, code, int(binary.BigEndian.Uint16(payload)))
+		return
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	hub := NewHub(HubConfig{})
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if c.GetHeader("X-User") != "" {
+			SetClaims(c, &Claims{UID: 7, Username: c.GetHeader("X-User")})
+		}
+	})
+	r.GET("/ws", WebSocket(WebSocketConfig{
+		Hub:          hub,
+		Topics:       func(*gin.Context) []string { return []string{"all"} },
+		Authorize:    func(_ *gin.Context, topic string) bool { return topic != "admin" },
+		PingInterval: 50 * time.Millisecond,
+		OnMessage: func(conn *WSConn, msg WSMessage) {
+			data, _ := json.Marshal(conn.Claims().Username)
+			_ = conn.Send(WSMessage{Type: "echo", ID: msg.ID, Data: data})
+		},
+	}))
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	_, resp := dialWS(t, s.URL, nil)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	_, resp = dialWS(t, s.URL, http.Header{"X-User": {"bob"}, "Origin": {"http://evil.example"}})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, resp = dialWS(t, s.URL, http.Header{"X-User": {"bob"}, "Sec-Websocket-Version": {"8"}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	hub.Publish("orders", Event{Event: "created", Data: []byte(`{"id":1}`)})
+	c, resp := dialWS(t, s.URL, http.Header{"X-User": {"bob"}})
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	// 业务消息，分片发送
+	c.writeFrame(false, wsOpText, []byte(`{"type":"hello",`))
+	c.writeFrame(true, wsOpPing, []byte("p"))
+	op, payload := c.readFrame()
+	for op == wsOpPing {
+		op, payload = c.readFrame()
+	}
+	require.Equal(t, byte(wsOpPong), op)
+	require.Equal(t, "p", string(payload))
+	c.writeFrame(true, wsOpContinuation, []byte(`"id":"1"}`))
+	require.Equal(t, WSMessage{Type: "echo", ID: "1", Data: json.RawMessage(`"bob"`)}, c.recv())
+
+	// 订阅并补发
+	c.send(WSMessage{Type: WSTypeSubscribe, Topic: "orders", ID: "0"})
+	require.Equal(t, WSMessage{Type: WSTypeEvent, Topic: "orders", ID: "1", Event: "created", Data: json.RawMessage(`{"id":1}`)}, c.recv())
+	c.send(WSMessage{Type: WSTypeSubscribe, Topic: "admin"})
+	msg := c.recv()
+	require.Equal(t, WSTypeError, msg.Type)
+	require.Contains(t, string(msg.Data), ErrPermissionDenied.Reason())
+
+	c.send(WSMessage{Type: WSTypePing, ID: "9"})
+	require.Equal(t, WSMessage{Type: WSTypePong, ID: "9"}, c.recv())
+
+	require.Eventually(t, func() bool { return hub.Len() == 1 }, time.Second, 10*time.Millisecond)
+	hub.Publish("all", Event{Data: []byte("plain text")})
+	require.Equal(t, WSMessage{Type: WSTypeEvent, Topic: "all", ID: "2", Data: json.RawMessage(`"plain text"`)}, c.recv())
+
+	c.send(WSMessage{Type: WSTypeUnsubscribe, Topic: "orders"})
+	c.send(WSMessage{Type: WSTypePing})
+	require.Equal(t, WSTypePong, c.recv().Type)
+	hub.Publish("orders", Event{Data: []byte("1")})
+	hub.Publish("all", Event{Data: []byte("2")})
+	require.Equal(t, "4", c.recv().ID)
+
+	// 关闭握手
+	c.writeFrame(true, wsOpClose, binary.BigEndian.AppendUint16(nil, WSCloseNormal))
+	c.expectClose(WSCloseNormal)
+	require.Eventually(t, func() bool { return hub.Len() == 0 }, time.Second, 10*time.Millisecond)
+
+	// 未加掩码的帧
+	c, _ = dialWS(t, s.URL, http.Header{"X-User": {"bob"}})
+	_, err := c.conn.Write([]byte{0x81, 0x01, 'x'})
+	require.NoError(t, err)
+	c.expectClose(WSCloseProtocolError)
+
+	// 超过大小限制
+	c, _ = dialWS(t, s.URL, http.Header{"X-User": {"bob"}})
+	c.writeFrame(true, wsOpText, make([]byte, 70<<10))
+	c.expectClose(WSCloseTooLarge)
+
+	// Hub 关闭时断开
+	c, _ = dialWS(t, s.URL, http.Header{"X-User": {"bob"}})
+	require.Eventually(t, func() bool { return hub.Len() == 1 }, time.Second, 10*time.Millisecond)
+	hub.Close()
+	c.expectClose(WSCloseGoingAway)
+}
+
+func TestWebSocketTokenLifetime(t *testing.T) {
+	store := NewMemoryRevokeStore()
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		claims := Claims{UID: 7}
+		claims.ID = c.GetHeader("X-Jti")
+		if c.GetHeader("X-Exp") != "" {
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(100 * time.Millisecond))
+		}
+		SetClaims(c, &claims)
+	})
+	r.GET("/ws", WebSocket(WebSocketConfig{Revoke: store, PingInterval: 50 * time.Millisecond}))
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	// 令牌过期后断开
+	c, _ := dialWS(t, s.URL, http.Header{"X-Exp": {"1"}})
+	c.expectClose(WSClosePolicyViolation)
+
+	// 令牌吊销后断开
+	c, _ = dialWS(t, s.URL, http.Header{"X-Jti": {"a"}})
+	op, _ := c.readFrame()
+	require.Equal(t, byte(wsOpPing), op)
+	require.NoError(t, store.Revoke(context.Background(), "a", time.Now().Add(time.Minute)))
+	c.expectClose(WSClosePolicyViolation)
+}